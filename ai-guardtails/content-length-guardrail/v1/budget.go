@@ -0,0 +1,214 @@
+package contentlengthguardrail
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+	utils "github.com/wso2/api-platform/sdk/utils"
+)
+
+const (
+	BudgetExceededErrorCode  = 429
+	DefaultBudgetWindow      = time.Hour
+	DefaultBudgetMaxSessions = 10000
+
+	// budgetBuckets is the number of buckets a session's usage within the window is coalesced
+	// into, which bounds the memory of each session
+	budgetBuckets = 60
+)
+
+// budgetConfig limits the total length a session may send over a sliding window, so a
+// prompt split across many requests is still bounded
+type budgetConfig struct {
+	limit           int
+	window          time.Duration
+	sessionHeader   string
+	sessionJsonPath string
+	store           *budgetStore
+}
+
+// parseBudgetConfig parses and validates the budget configuration
+func parseBudgetConfig(params map[string]interface{}) (*budgetConfig, error) {
+	c := &budgetConfig{window: DefaultBudgetWindow}
+
+	limitRaw, ok := params["limit"]
+	if !ok {
+		return nil, fmt.Errorf("'limit' is required")
+	}
+	limit, err := extractInt(limitRaw)
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("'limit' must be an integer greater than 0")
+	}
+	c.limit = limit
+
+	if windowRaw, ok := params["window"]; ok {
+		windowStr, ok := windowRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'window' must be a duration string, e.g. \"1h\"")
+		}
+		window, err := time.ParseDuration(windowStr)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("'window' must be a positive duration, e.g. \"1h\"")
+		}
+		c.window = window
+	}
+
+	if headerRaw, ok := params["sessionHeader"]; ok {
+		header, ok := headerRaw.(string)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("'sessionHeader' must be a non-empty string")
+		}
+		c.sessionHeader = header
+	}
+	if jsonPathRaw, ok := params["sessionJsonPath"]; ok {
+		jsonPath, ok := jsonPathRaw.(string)
+		if !ok || jsonPath == "" {
+			return nil, fmt.Errorf("'sessionJsonPath' must be a non-empty string")
+		}
+		if err := validateJSONPath(jsonPath); err != nil {
+			return nil, fmt.Errorf("invalid 'sessionJsonPath': %w", err)
+		}
+		c.sessionJsonPath = jsonPath
+	}
+	if (c.sessionHeader == "") == (c.sessionJsonPath == "") {
+		return nil, fmt.Errorf("exactly one of 'sessionHeader' or 'sessionJsonPath' is required")
+	}
+
+	maxSessions := DefaultBudgetMaxSessions
+	if maxSessionsRaw, ok := params["maxSessions"]; ok {
+		maxSessions, err = extractInt(maxSessionsRaw)
+		if err != nil || maxSessions <= 0 {
+			return nil, fmt.Errorf("'maxSessions' must be an integer greater than 0")
+		}
+	}
+	c.store = newBudgetStore(maxSessions, c.window)
+
+	return c, nil
+}
+
+// sessionKey returns the session identifier of the request, empty if it has none
+func (c *budgetConfig) sessionKey(headers *policy.Headers, payload []byte) string {
+	if c.sessionHeader != "" {
+		if values := headers.Get(c.sessionHeader); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	value, err := utils.ExtractStringValueFromJsonpath(payload, c.sessionJsonPath)
+	if err != nil {
+		return ""
+	}
+	return cleanValue(value)
+}
+
+// budgetStore tracks the lengths consumed by sessions in memory. Sessions idle for a whole
+// window are evicted, and beyond maxSessions the least recently used session is dropped.
+// Usage is summed per bucket of a sixtieth of the window, so a session holds at most
+// budgetBuckets entries; a bucket only leaves the window once all of it has.
+type budgetStore struct {
+	mu          sync.Mutex
+	maxSessions int
+	window      time.Duration
+	bucket      time.Duration
+	sessions    map[string]*list.Element
+	// lru orders sessions from most to least recently used
+	lru *list.List
+}
+
+// budgetSession is the usage of a session within the window, oldest first
+type budgetSession struct {
+	key      string
+	usage    []budgetUsage
+	lastSeen time.Time
+}
+
+// budgetUsage is the length consumed by requests in the bucket starting at a time
+type budgetUsage struct {
+	at     time.Time
+	amount int
+}
+
+func newBudgetStore(maxSessions int, window time.Duration) *budgetStore {
+	return &budgetStore{
+		maxSessions: maxSessions,
+		window:      window,
+		bucket:      max(window/budgetBuckets, time.Nanosecond),
+		sessions:    make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+// consume records amount against the session if it fits within limit over the window. It
+// returns the budget remaining afterwards, or the budget remaining before if it does not fit.
+func (s *budgetStore) consume(key string, amount, limit int, now time.Time) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-s.window)
+	s.evictExpired(cutoff)
+
+	var session *budgetSession
+	if elem, ok := s.sessions[key]; ok {
+		session = elem.Value.(*budgetSession)
+		s.lru.MoveToFront(elem)
+	} else {
+		if s.lru.Len() >= s.maxSessions {
+			s.evict(s.lru.Back())
+		}
+		session = &budgetSession{key: key}
+		s.sessions[key] = s.lru.PushFront(session)
+	}
+	session.lastSeen = now
+
+	// Drop buckets that slid out of the window
+	expired := 0
+	for expired < len(session.usage) && !session.usage[expired].at.Add(s.bucket).After(cutoff) {
+		expired++
+	}
+	session.usage = session.usage[expired:]
+
+	remaining := limit
+	for _, u := range session.usage {
+		remaining -= u.amount
+	}
+	if amount > remaining {
+		return max(remaining, 0), false
+	}
+	if amount == 0 {
+		return remaining, true
+	}
+	bucket := now.Truncate(s.bucket)
+	if last := len(session.usage) - 1; last >= 0 && session.usage[last].at.Equal(bucket) {
+		session.usage[last].amount += amount
+	} else {
+		session.usage = append(session.usage, budgetUsage{at: bucket, amount: amount})
+	}
+	return remaining - amount, true
+}
+
+// evictExpired evicts the sessions not seen since the cutoff
+func (s *budgetStore) evictExpired(cutoff time.Time) {
+	for elem := s.lru.Back(); elem != nil; elem = s.lru.Back() {
+		if elem.Value.(*budgetSession).lastSeen.After(cutoff) {
+			return
+		}
+		s.evict(elem)
+	}
+}
+
+func (s *budgetStore) evict(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.sessions, elem.Value.(*budgetSession).key)
+}
+
+// buildBudgetExceededResponse rejects a request that would exceed its session budget with 429,
+// or RESOURCE_EXHAUSTED for gRPC
+func (p *ContentLengthGuardrailPolicy) buildBudgetExceededResponse(count, remaining int, params ContentLengthGuardrailPolicyParams) policy.RequestAction {
+	reason := fmt.Sprintf("request length %d %s exceeds the remaining session budget of %d %s", count, params.Unit, remaining, params.Unit)
+	resp := p.buildErrorResponse(reason, nil, false, params.ShowAssessment, params.Min, params.Max, params.Unit,
+		&measurement{count: count, budget: params.Budget, remaining: remaining})
+	return p.withRequestStatus(resp, BudgetExceededErrorCode)
+}
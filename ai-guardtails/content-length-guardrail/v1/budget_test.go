@@ -0,0 +1,170 @@
+package contentlengthguardrail
+
+import (
+	"testing"
+	"time"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+func TestBudgetStoreConsume(t *testing.T) {
+	type step struct {
+		key       string
+		amount    int
+		after     time.Duration
+		remaining int
+		ok        bool
+	}
+
+	tests := []struct {
+		name        string
+		maxSessions int
+		steps       []step
+	}{
+		{
+			name:        "consumes until the limit",
+			maxSessions: 10,
+			steps: []step{
+				{key: "a", amount: 6, remaining: 4, ok: true},
+				{key: "a", amount: 4, remaining: 0, ok: true},
+				{key: "a", amount: 1, remaining: 0, ok: false},
+			},
+		},
+		{
+			name:        "rejected requests consume nothing",
+			maxSessions: 10,
+			steps: []step{
+				{key: "a", amount: 8, remaining: 2, ok: true},
+				{key: "a", amount: 5, remaining: 2, ok: false},
+				{key: "a", amount: 2, remaining: 0, ok: true},
+			},
+		},
+		{
+			name:        "sessions are separate",
+			maxSessions: 10,
+			steps: []step{
+				{key: "a", amount: 10, remaining: 0, ok: true},
+				{key: "b", amount: 10, remaining: 0, ok: true},
+			},
+		},
+		{
+			name:        "usage slides out of the window",
+			maxSessions: 10,
+			steps: []step{
+				{key: "a", amount: 6, remaining: 4, ok: true},
+				{key: "a", amount: 4, after: 30 * time.Minute, remaining: 0, ok: true},
+				{key: "a", amount: 6, after: 31 * time.Minute, remaining: 0, ok: true},
+				{key: "a", amount: 1, remaining: 0, ok: false},
+			},
+		},
+		{
+			name:        "least recently used session is evicted",
+			maxSessions: 2,
+			steps: []step{
+				{key: "a", amount: 10, remaining: 0, ok: true},
+				{key: "b", amount: 10, remaining: 0, ok: true},
+				{key: "a", amount: 1, remaining: 0, ok: false},
+				// c evicts b, which was used less recently than a
+				{key: "c", amount: 10, remaining: 0, ok: true},
+				{key: "b", amount: 10, remaining: 0, ok: true},
+				// b evicted a in turn
+				{key: "a", amount: 10, remaining: 0, ok: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newBudgetStore(tt.maxSessions, time.Hour)
+			now := time.Unix(0, 0)
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				remaining, ok := store.consume(s.key, s.amount, 10, now)
+				if remaining != s.remaining || ok != s.ok {
+					t.Fatalf("step %d: consume(%q, %d) = %d, %v, want %d, %v", i, s.key, s.amount, remaining, ok, s.remaining, s.ok)
+				}
+			}
+			if got := store.lru.Len(); got > tt.maxSessions {
+				t.Errorf("%d sessions tracked, want at most %d", got, tt.maxSessions)
+			}
+		})
+	}
+}
+
+func TestBudgetStoreEvictsIdleSessions(t *testing.T) {
+	store := newBudgetStore(10, time.Hour)
+	now := time.Unix(0, 0)
+	store.consume("a", 1, 10, now)
+	store.consume("b", 1, 10, now.Add(30*time.Minute))
+	store.consume("c", 1, 10, now.Add(61*time.Minute))
+
+	if _, ok := store.sessions["a"]; ok {
+		t.Error("idle session a was not evicted")
+	}
+	if got := store.lru.Len(); got != 2 {
+		t.Errorf("%d sessions tracked, want 2", got)
+	}
+}
+
+func TestBudgetStoreBoundsSessionUsage(t *testing.T) {
+	store := newBudgetStore(10, time.Hour)
+	now := time.Unix(0, 0)
+	// A request every 100ms for two windows
+	for i := 0; i < 72000; i++ {
+		store.consume("a", i%2, 1<<30, now.Add(time.Duration(i)*100*time.Millisecond))
+	}
+	usage := store.sessions["a"].Value.(*budgetSession).usage
+	if len(usage) > budgetBuckets+1 {
+		t.Errorf("%d usage entries kept, want at most %d", len(usage), budgetBuckets+1)
+	}
+	for _, u := range usage {
+		if u.amount == 0 {
+			t.Error("zero usage was recorded")
+		}
+	}
+}
+
+func TestBudgetRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		grpc   bool
+		status int
+		header string
+	}{
+		{name: "http", status: BudgetExceededErrorCode},
+		{name: "grpc", grpc: true, status: 200, header: "8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				"request": map[string]interface{}{
+					"unit":   "words",
+					"budget": map[string]interface{}{"limit": 5, "sessionHeader": "x-session-id"},
+				},
+			}
+			if tt.grpc {
+				params["grpc"] = map[string]interface{}{}
+			}
+			p := newTestPolicy(t, params)
+			session := map[string][]string{"x-session-id": {"s1"}}
+
+			if got := requestStatus(t, runRequest(p, session, "one two three", nil)); got != 0 {
+				t.Fatalf("first request status = %d, want 0", got)
+			}
+			// Requests without a session are not budgeted
+			if got := requestStatus(t, runRequest(p, nil, "one two three", nil)); got != 0 {
+				t.Fatalf("unbudgeted request status = %d, want 0", got)
+			}
+			action := runRequest(p, session, "four five six", nil)
+			if got := requestStatus(t, action); got != tt.status {
+				t.Fatalf("second request status = %d, want %d", got, tt.status)
+			}
+			if tt.header != "" {
+				if got := action.(policy.ImmediateResponse).Headers["grpc-status"]; got != tt.header {
+					t.Errorf("grpc-status = %q, want %q", got, tt.header)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package contentlengthguardrail
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	utils "github.com/wso2/api-platform/sdk/utils"
+)
+
+const (
+	DefaultChatMessagesPath = "$.messages"
+
+	// Which messages of the selected roles a chat limit applies to
+	ChatScopeAll    = "all"
+	ChatScopeLatest = "latest"
+
+	// ChatRoleSystem is the role given to an Anthropic top-level system prompt
+	ChatRoleSystem = "system"
+)
+
+// textPartTypes are the content part types carrying text, across OpenAI chat completions,
+// OpenAI responses and Anthropic messages
+var textPartTypes = map[string]bool{
+	"":            true,
+	"text":        true,
+	"input_text":  true,
+	"output_text": true,
+}
+
+// chatConfig applies separate length limits to chat messages by role
+type chatConfig struct {
+	messagesPath string
+	limits       []chatLimit
+}
+
+// chatLimit is a length range for the messages of some roles
+type chatLimit struct {
+	roles     map[string]bool
+	roleNames []string
+	min       int
+	max       int
+	// latest limits only the last message of the roles, e.g. the latest user turn
+	latest bool
+}
+
+// chatMessage is the text content of a chat message
+type chatMessage struct {
+	role string
+	text string
+	path string
+}
+
+// parseChatConfig parses and validates the chat configuration
+func parseChatConfig(params map[string]interface{}) (*chatConfig, error) {
+	c := &chatConfig{messagesPath: DefaultChatMessagesPath}
+
+	if messagesPathRaw, ok := params["messagesPath"]; ok {
+		messagesPath, ok := messagesPathRaw.(string)
+		if !ok || messagesPath == "" {
+			return nil, fmt.Errorf("'messagesPath' must be a non-empty string")
+		}
+		if err := validateJSONPath(messagesPath); err != nil {
+			return nil, fmt.Errorf("invalid 'messagesPath': %w", err)
+		}
+		c.messagesPath = messagesPath
+	}
+
+	limitsList, ok := params["limits"].([]interface{})
+	if !ok || len(limitsList) == 0 {
+		return nil, fmt.Errorf("'limits' is required and must be a non-empty array")
+	}
+	for i, limitRaw := range limitsList {
+		limitParams, ok := limitRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'limits[%d]' must be an object", i)
+		}
+		limit, err := parseChatLimit(limitParams)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'limits[%d]': %w", i, err)
+		}
+		c.limits = append(c.limits, limit)
+	}
+
+	return c, nil
+}
+
+// parseChatLimit parses and validates a chat role limit
+func parseChatLimit(params map[string]interface{}) (chatLimit, error) {
+	limit := chatLimit{roles: make(map[string]bool)}
+
+	rolesList, ok := params["roles"].([]interface{})
+	if !ok || len(rolesList) == 0 {
+		return limit, fmt.Errorf("'roles' is required and must be a non-empty array")
+	}
+	for _, roleRaw := range rolesList {
+		role, ok := roleRaw.(string)
+		if !ok || role == "" {
+			return limit, fmt.Errorf("'roles' must contain non-empty strings")
+		}
+		role = strings.ToLower(role)
+		if !limit.roles[role] {
+			limit.roles[role] = true
+			limit.roleNames = append(limit.roleNames, role)
+		}
+	}
+
+	min, max, err := parseRange(params)
+	if err != nil {
+		return limit, err
+	}
+	limit.min = min
+	limit.max = max
+
+	if scopeRaw, ok := params["scope"]; ok {
+		scope, ok := scopeRaw.(string)
+		if !ok || (scope != ChatScopeAll && scope != ChatScopeLatest) {
+			return limit, fmt.Errorf("'scope' must be either %q or %q", ChatScopeAll, ChatScopeLatest)
+		}
+		limit.latest = scope == ChatScopeLatest
+	}
+
+	return limit, nil
+}
+
+// extractChatMessages returns the text of each message at the messages path. Messages with
+// content arrays contribute their text parts; image, audio and tool parts are skipped.
+// An Anthropic top-level system prompt is returned as a system message.
+func extractChatMessages(payload []byte, messagesPath string) ([]chatMessage, error) {
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal(payload, &jsonData); err != nil {
+		return nil, err
+	}
+
+	messagesRaw, err := utils.ExtractValueFromJsonpath(jsonData, messagesPath)
+	if err != nil {
+		return nil, err
+	}
+	messagesList, ok := messagesRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value at %s is not an array", messagesPath)
+	}
+
+	var messages []chatMessage
+	if system, ok := jsonData["system"]; ok {
+		if text, ok := contentText(system); ok {
+			messages = append(messages, chatMessage{role: ChatRoleSystem, text: text, path: "$.system"})
+		}
+	}
+	for i, messageRaw := range messagesList {
+		message, ok := messageRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		role, _ := message["role"].(string)
+		text, ok := contentText(message["content"])
+		if !ok {
+			continue
+		}
+		messages = append(messages, chatMessage{
+			role: strings.ToLower(role),
+			text: text,
+			path: fmt.Sprintf("%s[%d].content", messagesPath, i),
+		})
+	}
+
+	return messages, nil
+}
+
+// contentText returns the text of message content given as a string or an array of parts
+func contentText(content interface{}) (string, bool) {
+	switch c := content.(type) {
+	case string:
+		return c, true
+	case []interface{}:
+		var texts []string
+		for _, partRaw := range c {
+			part, ok := partRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			partType, _ := part["type"].(string)
+			if text, ok := part["text"].(string); ok && textPartTypes[partType] {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, "\n"), true
+	default:
+		return "", false
+	}
+}
+
+// measureChat measures the messages selected by each chat limit in the configured unit.
+// With needTotal it also returns the total length of all messages.
+func measureChat(payload []byte, params ContentLengthGuardrailPolicyParams, needTotal bool) ([]measurement, int, error) {
+	messages, err := extractChatMessages(payload, params.Chat.messagesPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Messages selected by several limits are only measured once
+	counts := make([]int, len(messages))
+	rawCounts := make([]int, len(messages))
+	measured := make([]bool, len(messages))
+	countMessage := func(i int) (int, error) {
+		if !measured[i] {
+			count, rawCount, err := measureText(strings.TrimSpace(messages[i].text), params)
+			if err != nil {
+				return 0, err
+			}
+			counts[i] = count
+			rawCounts[i] = rawCount
+			measured[i] = true
+		}
+		return counts[i], nil
+	}
+
+	measurements := make([]measurement, 0, len(params.Chat.limits))
+	for _, limit := range params.Chat.limits {
+		m := measurement{min: limit.min, max: limit.max}
+		if limit.latest {
+			// With no message of the roles, the latest turn is empty
+			for i := len(messages) - 1; i >= 0; i-- {
+				if limit.roles[messages[i].role] {
+					count, err := countMessage(i)
+					if err != nil {
+						return nil, 0, err
+					}
+					m.count = count
+					m.rawCount = rawCounts[i]
+					m.path = messages[i].path
+					break
+				}
+			}
+		} else {
+			m.label = strings.Join(limit.roleNames, "/") + " messages"
+			for i, message := range messages {
+				if !limit.roles[message.role] {
+					continue
+				}
+				count, err := countMessage(i)
+				if err != nil {
+					return nil, 0, err
+				}
+				m.count += count
+				m.rawCount += rawCounts[i]
+				m.elements++
+			}
+		}
+		measurements = append(measurements, m)
+	}
+
+	total := 0
+	if needTotal {
+		for i := range messages {
+			count, err := countMessage(i)
+			if err != nil {
+				return nil, 0, err
+			}
+			total += count
+		}
+	}
+
+	return measurements, total, nil
+}
@@ -0,0 +1,158 @@
+package contentlengthguardrail
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractChatMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []chatMessage
+	}{
+		{
+			name:    "openai string content",
+			payload: `{"messages": [{"role": "System", "content": "be brief"}, {"role": "user", "content": "hi"}]}`,
+			want: []chatMessage{
+				{role: "system", text: "be brief", path: "$.messages[0].content"},
+				{role: "user", text: "hi", path: "$.messages[1].content"},
+			},
+		},
+		{
+			name: "content parts keep only text",
+			payload: `{"messages": [{"role": "user", "content": [
+				{"type": "text", "text": "look"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA"}},
+				{"type": "input_text", "text": "here"}
+			]}]}`,
+			want: []chatMessage{
+				{role: "user", text: "look\nhere", path: "$.messages[0].content"},
+			},
+		},
+		{
+			name:    "anthropic top-level system prompt",
+			payload: `{"system": "be brief", "messages": [{"role": "user", "content": "hi"}]}`,
+			want: []chatMessage{
+				{role: "system", text: "be brief", path: "$.system"},
+				{role: "user", text: "hi", path: "$.messages[0].content"},
+			},
+		},
+		{
+			name:    "messages without content are skipped",
+			payload: `{"messages": [{"role": "assistant", "tool_calls": []}, {"role": "tool", "content": "42"}]}`,
+			want: []chatMessage{
+				{role: "tool", text: "42", path: "$.messages[1].content"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractChatMessages([]byte(tt.payload), DefaultChatMessagesPath)
+			if err != nil {
+				t.Fatalf("extractChatMessages: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractChatMessages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatLimits(t *testing.T) {
+	// Two user turns of 2 and 4 words and a system prompt of 3 words
+	body := `{"messages": [
+		{"role": "system", "content": "be very brief"},
+		{"role": "user", "content": "hello there"},
+		{"role": "assistant", "content": "hi"},
+		{"role": "user", "content": "what is the time"}
+	]}`
+
+	tests := []struct {
+		name   string
+		limits []interface{}
+		status int
+	}{
+		{
+			name:   "all user messages within range",
+			limits: []interface{}{map[string]interface{}{"roles": []interface{}{"user"}, "min": 1, "max": 6}},
+			status: 0,
+		},
+		{
+			name:   "all user messages above range",
+			limits: []interface{}{map[string]interface{}{"roles": []interface{}{"user"}, "min": 1, "max": 5}},
+			status: GuardrailErrorCode,
+		},
+		{
+			name: "latest user message within range",
+			limits: []interface{}{map[string]interface{}{
+				"roles": []interface{}{"user"}, "min": 1, "max": 4, "scope": "latest",
+			}},
+			status: 0,
+		},
+		{
+			name: "latest user message above range",
+			limits: []interface{}{map[string]interface{}{
+				"roles": []interface{}{"user"}, "min": 1, "max": 3, "scope": "latest",
+			}},
+			status: GuardrailErrorCode,
+		},
+		{
+			name: "latest turn of a missing role is empty",
+			limits: []interface{}{map[string]interface{}{
+				"roles": []interface{}{"developer"}, "min": 1, "max": 10, "scope": "latest",
+			}},
+			status: GuardrailErrorCode,
+		},
+		{
+			name: "one of several limits violated",
+			limits: []interface{}{
+				map[string]interface{}{"roles": []interface{}{"user"}, "min": 1, "max": 6},
+				map[string]interface{}{"roles": []interface{}{"system"}, "min": 0, "max": 2},
+			},
+			status: GuardrailErrorCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPolicy(t, map[string]interface{}{
+				"request": map[string]interface{}{
+					"unit": "words",
+					"chat": map[string]interface{}{"limits": tt.limits},
+				},
+			})
+			if got := requestStatus(t, runRequest(p, nil, body, nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestParseChatConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{name: "no limits", params: map[string]interface{}{}},
+		{name: "empty messages path", params: map[string]interface{}{
+			"messagesPath": "",
+			"limits":       []interface{}{map[string]interface{}{"roles": []interface{}{"user"}, "min": 0, "max": 1}},
+		}},
+		{name: "no roles", params: map[string]interface{}{
+			"limits": []interface{}{map[string]interface{}{"min": 0, "max": 1}},
+		}},
+		{name: "unknown scope", params: map[string]interface{}{
+			"limits": []interface{}{map[string]interface{}{"roles": []interface{}{"user"}, "min": 0, "max": 1, "scope": "first"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseChatConfig(tt.params); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package contentlengthguardrail
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	// What to do with requests without a Content-Length header, e.g. chunked uploads,
+	// when the length is checked from the header
+	OnMissingContentLengthBuffer = "buffer"
+	OnMissingContentLengthReject = "reject"
+	OnMissingContentLengthAllow  = "allow"
+
+	LengthRequiredErrorCode = 411
+)
+
+// checksContentLengthHeader reports whether the length can be taken from the Content-Length
+// header, i.e. the whole raw body is measured in bytes and never rewritten, and neither a
+// session budget nor form limits need the body
+func (params ContentLengthGuardrailPolicyParams) checksContentLengthHeader() bool {
+	return params.HasRange && params.Chat == nil && params.Budget == nil && params.Form == nil && len(params.Normalize) == 0 &&
+		params.JsonPath == "" && params.Unit == UnitBytes && params.OnViolation == OnViolationReject
+}
+
+// checkContentLengthHeader validates the request length from its Content-Length header, so
+// oversize uploads are rejected without reading the body. It reports false if the request
+// has no usable header and the buffered body must be validated instead. The header of an
+// encoded body gives its compressed size, so encoded bodies are always decoded and validated;
+// if the body was not buffered they are rejected, never passed unchecked.
+func (p *ContentLengthGuardrailPolicy) checkContentLengthHeader(ctx *policy.RequestContext, params ContentLengthGuardrailPolicyParams) (policy.RequestAction, bool) {
+	if len(contentEncodings(ctx.Headers)) > 0 {
+		if ctx.Body != nil {
+			return nil, false
+		}
+		resp := p.buildErrorResponse("Content-Length header is required", errors.New("encoded request body was not buffered for decoding"),
+			false, params.ShowAssessment, params.Min, params.Max, params.Unit, nil)
+		return p.withRequestStatus(resp, LengthRequiredErrorCode), true
+	}
+
+	var contentLength int64 = -1
+	if values := ctx.Headers.Get("content-length"); len(values) > 0 {
+		if n, err := strconv.ParseInt(strings.TrimSpace(values[0]), 10, 64); err == nil && n >= 0 {
+			contentLength = n
+		}
+	}
+
+	if contentLength < 0 {
+		switch params.OnMissingContentLength {
+		case OnMissingContentLengthReject:
+			resp := p.buildErrorResponse("Content-Length header is required", errors.New("request has no valid Content-Length header"),
+				false, params.ShowAssessment, params.Min, params.Max, params.Unit, nil)
+			return p.withRequestStatus(resp, LengthRequiredErrorCode), true
+		case OnMissingContentLengthAllow:
+			return policy.UpstreamRequestModifications{}, true
+		default:
+			return nil, false
+		}
+	}
+
+	// Lengths beyond int range are clamped; they are far past any configurable max
+	count := int(min(contentLength, int64(^uint(0)>>1)))
+	if !passesRange(count, params.Min, params.Max, params.Invert) {
+		return p.buildViolationResponse(measurement{count: count, min: params.Min, max: params.Max}, params, false).(policy.RequestAction), true
+	}
+	if p.lengthRatio() != nil {
+		recordRequestLength(ctx.Metadata, count)
+	}
+	return policy.UpstreamRequestModifications{}, true
+}
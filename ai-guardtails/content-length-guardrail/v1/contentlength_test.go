@@ -0,0 +1,205 @@
+package contentlengthguardrail
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const oversizeUploadSize = 50 << 20
+
+func newOversizeUploadPolicy(b *testing.B, onMissingContentLength string) *ContentLengthGuardrailPolicy {
+	p, err := GetPolicy(policy.PolicyMetadata{}, map[string]interface{}{
+		"request": map[string]interface{}{
+			"min":                    0,
+			"max":                    1 << 20,
+			"onMissingContentLength": onMissingContentLength,
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return p.(*ContentLengthGuardrailPolicy)
+}
+
+// oversizeUploadContext builds the context the kernel passes for a 50 MB upload with a
+// Content-Length header. The body is only buffered if the policy's mode asks for it.
+func oversizeUploadContext(guardrail *ContentLengthGuardrailPolicy) *policy.RequestContext {
+	ctx := &policy.RequestContext{
+		SharedContext: &policy.SharedContext{Metadata: map[string]interface{}{}},
+		Headers:       policy.NewHeaders(map[string][]string{"content-length": {strconv.Itoa(oversizeUploadSize)}}),
+	}
+	if guardrail.Mode().RequestBodyMode == policy.BodyModeBuffer {
+		// Stands in for the kernel buffering the upload
+		ctx.Body = &policy.Body{Content: bytes.Repeat([]byte("x"), oversizeUploadSize), EndOfStream: true, Present: true}
+	}
+	return ctx
+}
+
+func benchmarkOversizeUpload(b *testing.B, onMissingContentLength string) {
+	guardrail := newOversizeUploadPolicy(b, onMissingContentLength)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := guardrail.OnRequest(oversizeUploadContext(guardrail), nil).(policy.ImmediateResponse); !ok {
+			b.Fatal("expected the upload to be rejected")
+		}
+	}
+}
+
+// BenchmarkOversizeUploadBuffered measures rejecting the upload when bodies are buffered as
+// a fallback for requests without Content-Length. Allocations include the buffered body.
+func BenchmarkOversizeUploadBuffered(b *testing.B) {
+	benchmarkOversizeUpload(b, OnMissingContentLengthBuffer)
+}
+
+// BenchmarkOversizeUploadHeaderOnly measures rejecting the same upload with the opt-in
+// header-only check, where the body is never buffered
+func BenchmarkOversizeUploadHeaderOnly(b *testing.B) {
+	benchmarkOversizeUpload(b, OnMissingContentLengthReject)
+}
+
+func TestContentLengthHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		onMissing string
+		grpc      bool
+		headers   map[string][]string
+		body      []byte
+		bodyMode  policy.BodyProcessingMode
+		status    int
+	}{
+		{
+			name:     "within max",
+			headers:  map[string][]string{"content-length": {"10"}},
+			body:     []byte("0123456789"),
+			bodyMode: policy.BodyModeBuffer,
+			status:   0,
+		},
+		{
+			name:     "above max",
+			headers:  map[string][]string{"content-length": {"11"}},
+			body:     []byte("0123456789a"),
+			bodyMode: policy.BodyModeBuffer,
+			status:   GuardrailErrorCode,
+		},
+		{
+			name:     "missing header falls back to the buffered body",
+			headers:  map[string][]string{},
+			body:     []byte("hello world"),
+			bodyMode: policy.BodyModeBuffer,
+			status:   GuardrailErrorCode,
+		},
+		{
+			name:     "buffered body measured as sent",
+			headers:  map[string][]string{},
+			body:     []byte(`  "abcdef"  `),
+			bodyMode: policy.BodyModeBuffer,
+			status:   GuardrailErrorCode,
+		},
+		{
+			name:     "header of an encoded body falls back to the buffered body",
+			headers:  map[string][]string{"content-length": {"5"}, "content-encoding": {"gzip"}},
+			body:     encode(t, []byte("hello world"), "gzip"),
+			bodyMode: policy.BodyModeBuffer,
+			status:   GuardrailErrorCode,
+		},
+		{
+			name:      "within max from the header only",
+			onMissing: OnMissingContentLengthReject,
+			headers:   map[string][]string{"content-length": {"10"}},
+			bodyMode:  policy.BodyModeSkip,
+			status:    0,
+		},
+		{
+			name:      "above max from the header only",
+			onMissing: OnMissingContentLengthReject,
+			headers:   map[string][]string{"content-length": {"11"}},
+			bodyMode:  policy.BodyModeSkip,
+			status:    GuardrailErrorCode,
+		},
+		{
+			name:      "missing header rejected",
+			onMissing: OnMissingContentLengthReject,
+			headers:   map[string][]string{},
+			bodyMode:  policy.BodyModeSkip,
+			status:    LengthRequiredErrorCode,
+		},
+		{
+			name:      "invalid header rejected",
+			onMissing: OnMissingContentLengthReject,
+			headers:   map[string][]string{"content-length": {"-1"}},
+			bodyMode:  policy.BodyModeSkip,
+			status:    LengthRequiredErrorCode,
+		},
+		{
+			name:      "missing header rejected over grpc",
+			onMissing: OnMissingContentLengthReject,
+			grpc:      true,
+			headers:   map[string][]string{},
+			bodyMode:  policy.BodyModeSkip,
+			status:    200,
+		},
+		{
+			name:      "encoded body rejected without buffering",
+			onMissing: OnMissingContentLengthAllow,
+			headers:   map[string][]string{"content-length": {"5"}, "content-encoding": {"gzip"}},
+			bodyMode:  policy.BodyModeSkip,
+			status:    LengthRequiredErrorCode,
+		},
+		{
+			name:      "missing header allowed",
+			onMissing: OnMissingContentLengthAllow,
+			headers:   map[string][]string{},
+			bodyMode:  policy.BodyModeSkip,
+			status:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := map[string]interface{}{"min": 0, "max": 10}
+			if tt.onMissing != "" {
+				request["onMissingContentLength"] = tt.onMissing
+			}
+			params := map[string]interface{}{"request": request}
+			if tt.grpc {
+				params["grpc"] = map[string]interface{}{}
+			}
+			p := newTestPolicy(t, params)
+			if got := p.Mode().RequestBodyMode; got != tt.bodyMode {
+				t.Errorf("request body mode = %v, want %v", got, tt.bodyMode)
+			}
+
+			ctx := &policy.RequestContext{
+				SharedContext: &policy.SharedContext{Metadata: map[string]interface{}{}},
+				Headers:       policy.NewHeaders(tt.headers),
+			}
+			if tt.bodyMode == policy.BodyModeBuffer {
+				ctx.Body = &policy.Body{Content: tt.body, EndOfStream: true, Present: true}
+			}
+			action := p.OnRequest(ctx, nil)
+			if got := requestStatus(t, action); got != tt.status {
+				t.Fatalf("status = %d, want %d", got, tt.status)
+			}
+			if tt.grpc {
+				if got := action.(policy.ImmediateResponse).Headers["grpc-status"]; got != "9" {
+					t.Errorf("grpc-status = %q, want %q", got, "9")
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidOnMissingContentLength(t *testing.T) {
+	for _, value := range []interface{}{"drop", "", true} {
+		_, err := GetPolicy(policy.PolicyMetadata{}, map[string]interface{}{
+			"request": map[string]interface{}{"min": 0, "max": 10, "onMissingContentLength": value},
+		})
+		if err == nil {
+			t.Errorf("onMissingContentLength %v: expected an error", value)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/tiktoken-go/tokenizer"
 	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
 	utils "github.com/wso2/api-platform/sdk/utils"
 )
@@ -14,8 +16,40 @@ import (
 const (
 	GuardrailErrorCode = 422
 	TextCleanRegex     = "^\"|\"$"
+
+	// GuardrailGRPCStatus is the gRPC status returned to gRPC clients (INVALID_ARGUMENT, the gRPC equivalent of 422)
+	GuardrailGRPCStatus = 3
+	GRPCContentType     = "application/grpc"
 )
 
+// grpcStatusMapping maps the HTTP status of rejections other than violations to the gRPC
+// status with the closest meaning. Violations use the configured grpc status.
+var grpcStatusMapping = map[int]int{
+	LengthRequiredErrorCode: 9, // FAILED_PRECONDITION
+	BudgetExceededErrorCode: 8, // RESOURCE_EXHAUSTED
+}
+
+// grpcCodes maps gRPC status code names to their values
+var grpcCodes = map[string]int{
+	"OK":                  0,
+	"CANCELLED":           1,
+	"UNKNOWN":             2,
+	"INVALID_ARGUMENT":    3,
+	"DEADLINE_EXCEEDED":   4,
+	"NOT_FOUND":           5,
+	"ALREADY_EXISTS":      6,
+	"PERMISSION_DENIED":   7,
+	"RESOURCE_EXHAUSTED":  8,
+	"FAILED_PRECONDITION": 9,
+	"ABORTED":             10,
+	"OUT_OF_RANGE":        11,
+	"UNIMPLEMENTED":       12,
+	"INTERNAL":            13,
+	"UNAVAILABLE":         14,
+	"DATA_LOSS":           15,
+	"UNAUTHENTICATED":     16,
+}
+
 var textCleanRegexCompiled = regexp.MustCompile(TextCleanRegex)
 
 // ContentLengthGuardrailPolicy implements content length validation
@@ -24,21 +58,54 @@ type ContentLengthGuardrailPolicy struct {
 	hasResponseParams bool
 	requestParams     ContentLengthGuardrailPolicyParams
 	responseParams    ContentLengthGuardrailPolicyParams
+
+	// gRPC routes get violations as grpc-status/grpc-message instead of a JSON body
+	grpcEnabled bool
+	grpcStatus  int
+
+	// decompression caps bodies decoded from their Content-Encoding
+	decompression decompressionLimits
 }
 
 type ContentLengthGuardrailPolicyParams struct {
 	Min            int
 	Max            int
+	HasRange       bool
 	JsonPath       string
 	Invert         bool
 	ShowAssessment bool
+	Unit           string
+	CharacterMode  string
+	Tokenizer      string
+	Aggregate      string
+	Normalize      []string
+
+	OnViolation      string
+	TruncationMarker string
+
+	OnMissingContentLength string
+
+	Chat   *chatConfig
+	Tiers  *tierConfig
+	Ratio  *ratioConfig
+	Budget *budgetConfig
+	Form   *formConfig
+
+	// tokenizer is the codec loaded for the tokens unit
+	tokenizer tokenizer.Codec
+	// sessionID is the request's budget session, resolved per request
+	sessionID string
+	// rawBytes measures the whole body as sent, like its Content-Length header
+	rawBytes bool
 }
 
 func GetPolicy(
 	metadata policy.PolicyMetadata,
 	params map[string]interface{},
 ) (policy.Policy, error) {
-	p := &ContentLengthGuardrailPolicy{}
+	p := &ContentLengthGuardrailPolicy{
+		decompression: decompressionLimits{maxSize: DefaultMaxDecompressedSize, maxRatio: DefaultMaxDecompressionRatio},
+	}
 
 	// Extract and parse request parameters if present
 	if requestParamsRaw, ok := params["request"].(map[string]interface{}); ok {
@@ -65,47 +132,147 @@ func GetPolicy(
 		return nil, fmt.Errorf("at least one of 'request' or 'response' parameters must be provided")
 	}
 
+	// The ratio compares the response to the length measured in the request phase
+	if p.hasRequestParams && p.requestParams.Ratio != nil {
+		return nil, fmt.Errorf("invalid request parameters: 'ratio' is only supported in response parameters")
+	}
+	if p.hasResponseParams && p.responseParams.Ratio != nil {
+		if !p.hasRequestParams {
+			return nil, fmt.Errorf("invalid response parameters: 'ratio' requires 'request' parameters to measure the request")
+		}
+		if p.requestParams.Unit != p.responseParams.Unit {
+			return nil, fmt.Errorf("invalid response parameters: 'ratio' requires the request and response to use the same 'unit'")
+		}
+	}
+
+	// Budgets track what sessions send, so only apply to requests
+	if p.hasResponseParams && p.responseParams.Budget != nil {
+		return nil, fmt.Errorf("invalid response parameters: 'budget' is only supported in request parameters")
+	}
+	if p.hasResponseParams && p.responseParams.Form != nil {
+		return nil, fmt.Errorf("invalid response parameters: 'form' is only supported in request parameters")
+	}
+
+	// Extract optional grpc parameters
+	if grpcRaw, ok := params["grpc"].(map[string]interface{}); ok {
+		p.grpcEnabled = true
+		p.grpcStatus = GuardrailGRPCStatus
+		if statusRaw, ok := grpcRaw["status"]; ok {
+			status, err := extractGRPCStatus(statusRaw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid grpc parameters: 'status' %w", err)
+			}
+			p.grpcStatus = status
+		}
+	}
+
+	// Extract optional decompression parameters
+	if decompressionRaw, ok := params["decompression"].(map[string]interface{}); ok {
+		limits, err := parseDecompressionLimits(decompressionRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decompression parameters: %w", err)
+		}
+		p.decompression = limits
+	}
+
 	return p, nil
 }
 
 // parseParams parses and validates parameters from map to struct
 func parseParams(params map[string]interface{}) (ContentLengthGuardrailPolicyParams, error) {
-	var result ContentLengthGuardrailPolicyParams
-
-	// Validate and extract min parameter (required)
-	minRaw, ok := params["min"]
-	if !ok {
-		return result, fmt.Errorf("'min' parameter is required")
+	result := ContentLengthGuardrailPolicyParams{
+		Unit:          UnitBytes,
+		CharacterMode: CharacterModeCodePoints,
+		Tokenizer:     DefaultTokenizer,
+		Aggregate:     AggregateSum,
+		OnViolation:   OnViolationReject,
+
+		OnMissingContentLength: OnMissingContentLengthBuffer,
 	}
-	min, err := extractInt(minRaw)
-	if err != nil {
-		return result, fmt.Errorf("'min' must be a number: %w", err)
+
+	// Extract optional chat parameter
+	if chatRaw, ok := params["chat"]; ok {
+		chatParams, ok := chatRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'chat' must be an object")
+		}
+		chat, err := parseChatConfig(chatParams)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'chat': %w", err)
+		}
+		result.Chat = chat
 	}
-	if min < 0 {
-		return result, fmt.Errorf("'min' cannot be negative")
+
+	// Extract optional ratio parameter
+	if ratioRaw, ok := params["ratio"]; ok {
+		ratioParams, ok := ratioRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'ratio' must be an object")
+		}
+		ratio, err := parseRatioConfig(ratioParams)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'ratio': %w", err)
+		}
+		result.Ratio = ratio
 	}
-	result.Min = min
 
-	// Validate and extract max parameter (required)
-	maxRaw, ok := params["max"]
-	if !ok {
-		return result, fmt.Errorf("'max' parameter is required")
+	// Extract optional budget parameter
+	if budgetRaw, ok := params["budget"]; ok {
+		budgetParams, ok := budgetRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'budget' must be an object")
+		}
+		budget, err := parseBudgetConfig(budgetParams)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'budget': %w", err)
+		}
+		result.Budget = budget
 	}
-	max, err := extractInt(maxRaw)
-	if err != nil {
-		return result, fmt.Errorf("'max' must be a number: %w", err)
+
+	// Extract optional form parameter
+	if formRaw, ok := params["form"]; ok {
+		formParams, ok := formRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'form' must be an object")
+		}
+		form, err := parseFormConfig(formParams)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'form': %w", err)
+		}
+		result.Form = form
 	}
-	if max <= 0 {
-		return result, fmt.Errorf("'max' must be greater than 0")
+
+	// min and max are required unless only chat role limits, a ratio, a budget or form limits are configured
+	_, hasMin := params["min"]
+	_, hasMax := params["max"]
+	if (result.Chat == nil && result.Ratio == nil && result.Budget == nil && result.Form == nil) || hasMin || hasMax {
+		min, max, err := parseRange(params)
+		if err != nil {
+			return result, err
+		}
+		result.Min = min
+		result.Max = max
+		result.HasRange = true
 	}
-	if min > max {
-		return result, fmt.Errorf("'min' cannot be greater than 'max'")
+
+	// Extract optional tiers parameter; min and max form the default tier
+	if _, ok := params["tiers"]; ok {
+		if !result.HasRange {
+			return result, fmt.Errorf("'tiers' require 'min' and 'max' as the default tier")
+		}
+		tiers, err := parseTierConfig(params)
+		if err != nil {
+			return result, err
+		}
+		result.Tiers = tiers
 	}
-	result.Max = max
 
 	// Extract optional jsonPath parameter
 	if jsonPathRaw, ok := params["jsonPath"]; ok {
 		if jsonPath, ok := jsonPathRaw.(string); ok {
+			if err := validateJSONPath(jsonPath); err != nil {
+				return result, fmt.Errorf("invalid 'jsonPath': %w", err)
+			}
 			result.JsonPath = jsonPath
 		} else {
 			return result, fmt.Errorf("'jsonPath' must be a string")
@@ -130,6 +297,98 @@ func parseParams(params map[string]interface{}) (ContentLengthGuardrailPolicyPar
 		}
 	}
 
+	// Extract optional unit parameter
+	if unitRaw, ok := params["unit"]; ok {
+		if unit, ok := unitRaw.(string); ok && validUnits[unit] {
+			result.Unit = unit
+		} else {
+			return result, fmt.Errorf("'unit' must be one of bytes, characters, words, sentences, lines or tokens")
+		}
+	}
+
+	// Extract optional characterMode parameter
+	if characterModeRaw, ok := params["characterMode"]; ok {
+		if characterMode, ok := characterModeRaw.(string); ok && (characterMode == CharacterModeCodePoints || characterMode == CharacterModeGraphemes) {
+			result.CharacterMode = characterMode
+		} else {
+			return result, fmt.Errorf("'characterMode' must be either %q or %q", CharacterModeCodePoints, CharacterModeGraphemes)
+		}
+	}
+
+	// Extract optional tokenizer parameter
+	if tokenizerRaw, ok := params["tokenizer"]; ok {
+		name, ok := tokenizerRaw.(string)
+		if !ok {
+			return result, fmt.Errorf("'tokenizer' must be a string")
+		}
+		result.Tokenizer = name
+	}
+
+	// Extract optional aggregate parameter
+	if aggregateRaw, ok := params["aggregate"]; ok {
+		if aggregate, ok := aggregateRaw.(string); ok && (aggregate == AggregateSum || aggregate == AggregateMax || aggregate == AggregateEach) {
+			result.Aggregate = aggregate
+		} else {
+			return result, fmt.Errorf("'aggregate' must be one of sum, max or each")
+		}
+	}
+
+	// Extract optional normalize parameter
+	if normalizeRaw, ok := params["normalize"]; ok {
+		normalize, err := parseNormalize(normalizeRaw)
+		if err != nil {
+			return result, err
+		}
+		result.Normalize = normalize
+	}
+
+	// Extract optional onViolation parameter
+	if onViolationRaw, ok := params["onViolation"]; ok {
+		if onViolation, ok := onViolationRaw.(string); ok && (onViolation == OnViolationReject || onViolation == OnViolationTruncate) {
+			result.OnViolation = onViolation
+		} else {
+			return result, fmt.Errorf("'onViolation' must be either %q or %q", OnViolationReject, OnViolationTruncate)
+		}
+	}
+	if result.OnViolation == OnViolationTruncate {
+		if result.Invert {
+			return result, fmt.Errorf("'onViolation' truncate cannot be used with 'invert'")
+		}
+		if isWildcardJSONPath(result.JsonPath) && result.Aggregate == AggregateSum {
+			return result, fmt.Errorf("'onViolation' truncate requires 'aggregate' max or each for wildcard JSONPaths")
+		}
+		if result.Chat != nil {
+			return result, fmt.Errorf("'onViolation' truncate cannot be used with 'chat'")
+		}
+	}
+
+	// Extract optional truncationMarker parameter
+	if markerRaw, ok := params["truncationMarker"]; ok {
+		marker, ok := markerRaw.(string)
+		if !ok {
+			return result, fmt.Errorf("'truncationMarker' must be a string")
+		}
+		result.TruncationMarker = marker
+	}
+
+	// Extract optional onMissingContentLength parameter
+	if onMissingRaw, ok := params["onMissingContentLength"]; ok {
+		if onMissing, ok := onMissingRaw.(string); ok && (onMissing == OnMissingContentLengthBuffer || onMissing == OnMissingContentLengthReject || onMissing == OnMissingContentLengthAllow) {
+			result.OnMissingContentLength = onMissing
+		} else {
+			return result, fmt.Errorf("'onMissingContentLength' must be one of buffer, reject or allow")
+		}
+	}
+
+	// Load the vocabulary once here so requests only run the encoder
+	if result.Unit == UnitTokens {
+		codec, err := loadTokenizer(result.Tokenizer)
+		if err != nil {
+			return result, err
+		}
+		result.tokenizer = codec
+	}
+
 	return result, nil
 }
 
@@ -159,27 +418,132 @@ func extractInt(value interface{}) (int, error) {
 	}
 }
 
+// parseRange parses and validates the min and max parameters
+func parseRange(params map[string]interface{}) (int, int, error) {
+	// Validate and extract min parameter (required)
+	minRaw, ok := params["min"]
+	if !ok {
+		return 0, 0, fmt.Errorf("'min' parameter is required")
+	}
+	min, err := extractInt(minRaw)
+	if err != nil {
+		return 0, 0, fmt.Errorf("'min' must be a number: %w", err)
+	}
+	if min < 0 {
+		return 0, 0, fmt.Errorf("'min' cannot be negative")
+	}
+
+	// Validate and extract max parameter (required)
+	maxRaw, ok := params["max"]
+	if !ok {
+		return 0, 0, fmt.Errorf("'max' parameter is required")
+	}
+	max, err := extractInt(maxRaw)
+	if err != nil {
+		return 0, 0, fmt.Errorf("'max' must be a number: %w", err)
+	}
+	if max <= 0 {
+		return 0, 0, fmt.Errorf("'max' must be greater than 0")
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("'min' cannot be greater than 'max'")
+	}
+	return min, max, nil
+}
+
+// extractGRPCStatus extracts a gRPC status code given as a number or name (e.g. "INVALID_ARGUMENT")
+func extractGRPCStatus(value interface{}) (int, error) {
+	if name, ok := value.(string); ok {
+		if code, ok := grpcCodes[strings.ToUpper(name)]; ok {
+			return code, nil
+		}
+	}
+	code, err := extractInt(value)
+	if err != nil {
+		return 0, fmt.Errorf("must be a gRPC status code number or name: %w", err)
+	}
+	if code < 0 || code > 16 {
+		return 0, fmt.Errorf("must be between 0 and 16")
+	}
+	return code, nil
+}
+
 // Mode returns the processing mode for this policy
 func (p *ContentLengthGuardrailPolicy) Mode() policy.ProcessingMode {
+	requestHeaderMode := policy.HeaderModeSkip
+	requestBodyMode := policy.BodyModeBuffer
+	if p.hasRequestParams {
+		// Need Content-Encoding to decode the body, and the content type and session header
+		requestHeaderMode = policy.HeaderModeProcess
+	}
+	if p.usesTierHeader() {
+		requestHeaderMode = policy.HeaderModeProcess // Need the tier header to select limits
+	}
+	if p.hasRequestParams && p.requestParams.checksContentLengthHeader() {
+		requestHeaderMode = policy.HeaderModeProcess // Oversize requests are rejected from Content-Length
+		if p.requestParams.OnMissingContentLength != OnMissingContentLengthBuffer {
+			requestBodyMode = policy.BodyModeSkip // Length is only taken from the header
+		}
+	}
+
+	responseHeaderMode := policy.HeaderModeSkip
+	if p.hasResponseParams {
+		responseHeaderMode = policy.HeaderModeProcess // Need content type and encoding to read the body
+	}
 	return policy.ProcessingMode{
-		RequestHeaderMode:  policy.HeaderModeSkip,
-		RequestBodyMode:    policy.BodyModeBuffer,
-		ResponseHeaderMode: policy.HeaderModeSkip,
+		RequestHeaderMode:  requestHeaderMode,
+		RequestBodyMode:    requestBodyMode,
+		ResponseHeaderMode: responseHeaderMode,
 		ResponseBodyMode:   policy.BodyModeBuffer,
 	}
 }
 
+// usesTierHeader reports whether tiers are selected by a request header in either phase
+func (p *ContentLengthGuardrailPolicy) usesTierHeader() bool {
+	return (p.hasRequestParams && p.requestParams.Tiers != nil && p.requestParams.Tiers.header != "") ||
+		(p.hasResponseParams && p.responseParams.Tiers != nil && p.responseParams.Tiers.header != "")
+}
+
 // OnRequest validates request body content length
 func (p *ContentLengthGuardrailPolicy) OnRequest(ctx *policy.RequestContext, params map[string]interface{}) policy.RequestAction {
 	if !p.hasRequestParams {
 		return policy.UpstreamRequestModifications{}
 	}
 
+	requestParams := p.requestParams.resolveTier(ctx.Metadata, ctx.Headers)
+	if requestParams.checksContentLengthHeader() {
+		if action, handled := p.checkContentLengthHeader(ctx, requestParams); handled {
+			return action
+		}
+		requestParams.rawBytes = true
+	}
+
 	var content []byte
 	if ctx.Body != nil {
 		content = ctx.Body.Content
 	}
-	return p.validatePayload(content, p.requestParams, false).(policy.RequestAction)
+	codings := contentEncodings(ctx.Headers)
+	content, err := decompressBody(content, codings, p.decompression)
+	if err != nil {
+		return p.buildErrorResponse("Error decompressing request body", err, false, requestParams.ShowAssessment,
+			requestParams.Min, requestParams.Max, requestParams.Unit, nil).(policy.RequestAction)
+	}
+	// Form bodies are validated by part, then as a whole. The encoded body is not JSON, so it is
+	// measured without the jsonPath and chat limits, and is rejected rather than truncated.
+	if requestParams.Form != nil {
+		if mediaType, mediaParams := formMediaType(ctx.Headers); mediaType != "" {
+			if action := p.validateForm(content, mediaType, mediaParams, requestParams); action != nil {
+				return action
+			}
+			requestParams.JsonPath = ""
+			requestParams.Chat = nil
+			requestParams.OnViolation = OnViolationReject
+		}
+	}
+	if requestParams.Budget != nil {
+		requestParams.sessionID = requestParams.Budget.sessionKey(ctx.Headers, content)
+	}
+	return withoutRequestContentEncoding(p.validatePayload(content, requestParams, false, ctx.Metadata).(policy.RequestAction), codings)
 }
 
 // OnResponse validates response body content length
@@ -192,42 +556,110 @@ func (p *ContentLengthGuardrailPolicy) OnResponse(ctx *policy.ResponseContext, p
 	if ctx.ResponseBody != nil {
 		content = ctx.ResponseBody.Content
 	}
-	return p.validatePayload(content, p.responseParams, true).(policy.ResponseAction)
+
+	responseParams := p.responseParams.resolveTier(ctx.Metadata, ctx.RequestHeaders)
+
+	codings := contentEncodings(ctx.ResponseHeaders)
+	content, err := decompressBody(content, codings, p.decompression)
+	if err != nil {
+		return withoutContentEncoding(p.buildErrorResponse("Error decompressing response body", err, true, responseParams.ShowAssessment,
+			responseParams.Min, responseParams.Max, responseParams.Unit, nil).(policy.ResponseAction), codings)
+	}
+
+	// Streamed LLM responses are validated on the text reassembled from their delta events
+	if isEventStream(ctx.ResponseHeaders, content) {
+		return withoutContentEncoding(p.validateEventStream(content, responseParams, ctx.Metadata), codings)
+	}
+	return withoutContentEncoding(p.validatePayload(content, responseParams, true, ctx.Metadata).(policy.ResponseAction), codings)
+}
+
+// measurement is a content length to validate against the range
+type measurement struct {
+	count int
+	// min and max are the range the count is validated against
+	min int
+	max int
+	// path is the concrete JSONPath of the measured element, empty if the whole extracted value was measured
+	path string
+	// elements is the number of elements summed into count, 0 if not aggregated
+	elements int
+	// label names the summed elements, e.g. "user messages"
+	label string
+	// rawCount is the length before normalization
+	rawCount int
+	// part is the measured form part; with partCount, count is the number of parts
+	part      *formPart
+	partCount bool
+	// ratio is set if the response length violated the ratio to requestLength
+	ratio         *ratioConfig
+	requestLength int
+	// budget is set if the request exceeded the remaining session budget
+	budget    *budgetConfig
+	remaining int
 }
 
 // validatePayload validates payload content length (request phase)
-func (p *ContentLengthGuardrailPolicy) validatePayload(payload []byte, params ContentLengthGuardrailPolicyParams, isResponse bool) interface{} {
-	// Extract value using JSONPath
-	extractedValue, err := utils.ExtractStringValueFromJsonpath(payload, params.JsonPath)
+func (p *ContentLengthGuardrailPolicy) validatePayload(payload []byte, params ContentLengthGuardrailPolicyParams, isResponse bool, metadata map[string]interface{}) interface{} {
+	ratio := p.lengthRatio()
+	budgeted := !isResponse && params.Budget != nil && params.sessionID != ""
+	measurements, total, reason, err := measurePayload(payload, params, ratio != nil || budgeted)
 	if err != nil {
-		return p.buildErrorResponse("Error extracting value from JSONPath", err, isResponse, params.ShowAssessment, params.Min, params.Max)
+		return p.buildErrorResponse(reason, err, isResponse, params.ShowAssessment, params.Min, params.Max, params.Unit, nil)
 	}
 
-	// Clean and trim
-	extractedValue = textCleanRegexCompiled.ReplaceAllString(extractedValue, "")
-	extractedValue = strings.TrimSpace(extractedValue)
+	truncate := false
+	for _, m := range measurements {
+		validationPassed := passesRange(m.count, m.min, m.max, params.Invert)
+
+		// Content that is only too long is truncated instead of rejected when configured
+		if !validationPassed && params.OnViolation == OnViolationTruncate && m.count > m.max {
+			truncate = true
+			continue
+		}
 
-	// Count bytes
-	byteCount := len([]byte(extractedValue))
+		if !validationPassed {
+			return p.buildViolationResponse(m, params, isResponse)
+		}
+	}
 
-	// Check if within range
-	isWithinRange := byteCount >= params.Min && byteCount <= params.Max
+	// Ratio and budget apply to the content that is forwarded, so truncation comes first
+	var truncated *truncation
+	if truncate {
+		truncated, err = truncatePayload(payload, params)
+		if err != nil {
+			return p.buildErrorResponse("Error truncating content", err, isResponse, params.ShowAssessment, params.Min, params.Max, params.Unit, nil)
+		}
+		if ratio != nil || budgeted {
+			_, total, reason, err = measurePayload(truncated.payload, params, true)
+			if err != nil {
+				return p.buildErrorResponse(reason, err, isResponse, params.ShowAssessment, params.Min, params.Max, params.Unit, nil)
+			}
+		}
+	}
 
-	var validationPassed bool
-	if params.Invert {
-		validationPassed = !isWithinRange // Inverted: pass if NOT in range
-	} else {
-		validationPassed = isWithinRange // Normal: pass if in range
+	if ratio != nil {
+		if !isResponse {
+			recordRequestLength(metadata, total)
+		} else if requestLength, ok := metadata[MetadataKeyRequestLength].(int); ok && !ratio.passes(requestLength, total) {
+			reason := fmt.Sprintf("response length %d %s is not %s the request length %d %s", total, params.Unit, ratio.describe(), requestLength, params.Unit)
+			return p.buildErrorResponse(reason, nil, isResponse, params.ShowAssessment, params.Min, params.Max, params.Unit,
+				&measurement{count: total, ratio: ratio, requestLength: requestLength})
+		}
 	}
 
-	if !validationPassed {
-		var reason string
-		if params.Invert {
-			reason = fmt.Sprintf("content length %d bytes is within the excluded range %d-%d bytes", byteCount, params.Min, params.Max)
-		} else {
-			reason = fmt.Sprintf("content length %d bytes is outside the allowed range %d-%d bytes", byteCount, params.Min, params.Max)
+	// Requests without a session identifier are not budgeted
+	if budgeted {
+		if remaining, ok := params.Budget.store.consume(params.sessionID, total, params.Budget.limit, time.Now()); !ok {
+			return p.buildBudgetExceededResponse(total, remaining, params)
+		}
+	}
+
+	if truncated != nil {
+		recordTruncation(metadata, truncated, isResponse)
+		if isResponse {
+			return policy.UpstreamResponseModifications{Body: truncated.payload}
 		}
-		return p.buildErrorResponse(reason, nil, isResponse, params.ShowAssessment, params.Min, params.Max)
+		return policy.UpstreamRequestModifications{Body: truncated.payload}
 	}
 
 	if isResponse {
@@ -236,9 +668,142 @@ func (p *ContentLengthGuardrailPolicy) validatePayload(payload []byte, params Co
 	return policy.UpstreamRequestModifications{}
 }
 
-// buildErrorResponse builds an error response for both request and response phases
-func (p *ContentLengthGuardrailPolicy) buildErrorResponse(reason string, validationError error, isResponse bool, showAssessment bool, min, max int) interface{} {
-	assessment := p.buildAssessmentObject(reason, validationError, isResponse, showAssessment, min, max)
+// passesRange reports whether a length satisfies the range
+func passesRange(count, min, max int, invert bool) bool {
+	// Check if within range
+	isWithinRange := count >= min && count <= max
+
+	if invert {
+		return !isWithinRange // Inverted: pass if NOT in range
+	}
+	return isWithinRange // Normal: pass if in range
+}
+
+// buildViolationResponse builds the error response for a measurement outside the range
+func (p *ContentLengthGuardrailPolicy) buildViolationResponse(m measurement, params ContentLengthGuardrailPolicyParams, isResponse bool) interface{} {
+	subject := "content length"
+	if m.part != nil {
+		subject = fmt.Sprintf("length of form part %q", m.part.name)
+	} else if m.path != "" {
+		subject = fmt.Sprintf("content length at %s", m.path)
+	} else if m.label != "" {
+		subject = fmt.Sprintf("total content length of %d %s", m.elements, m.label)
+	}
+
+	var reason string
+	if params.Invert {
+		reason = fmt.Sprintf("%s %d %s is within the excluded range %d-%d %s", subject, m.count, params.Unit, m.min, m.max, params.Unit)
+	} else {
+		reason = fmt.Sprintf("%s %d %s is outside the allowed range %d-%d %s", subject, m.count, params.Unit, m.min, m.max, params.Unit)
+	}
+	return p.buildErrorResponse(reason, nil, isResponse, params.ShowAssessment, m.min, m.max, params.Unit, &m)
+}
+
+// measurePayload measures the payload for every configured range: the value at the JSONPath
+// and the chat role limits. With needTotal it also returns the total length of the content,
+// i.e. the value at the JSONPath with wildcard matches summed, or all chat messages if only
+// chat limits are configured. On failure it also returns the reason to report.
+func measurePayload(payload []byte, params ContentLengthGuardrailPolicyParams, needTotal bool) ([]measurement, int, string, error) {
+	var measurements []measurement
+	total := 0
+	if params.HasRange || (needTotal && params.Chat == nil) {
+		valueMeasurements, valueTotal, reason, err := measureValue(payload, params)
+		if err != nil {
+			return nil, 0, reason, err
+		}
+		if params.HasRange {
+			measurements = append(measurements, valueMeasurements...)
+		}
+		total = valueTotal
+	}
+	if params.Chat != nil {
+		chatMeasurements, chatTotal, err := measureChat(payload, params, needTotal && !params.HasRange)
+		if err != nil {
+			return nil, 0, "Error extracting chat messages", err
+		}
+		measurements = append(measurements, chatMeasurements...)
+		if !params.HasRange {
+			total = chatTotal
+		}
+	}
+	return measurements, total, "", nil
+}
+
+// measureValue extracts the value(s) at the JSONPath and measures them in the configured unit.
+// Wildcard JSONPaths yield one measurement for sum and max, and one per element for each.
+// It also returns the total length of all values.
+func measureValue(payload []byte, params ContentLengthGuardrailPolicyParams) ([]measurement, int, string, error) {
+	if params.rawBytes {
+		// Quotes and whitespace are kept so the count matches the Content-Length header
+		count := len(payload)
+		return []measurement{{count: count, min: params.Min, max: params.Max, rawCount: count}}, count, "", nil
+	}
+	if !isWildcardJSONPath(params.JsonPath) {
+		// Extract value using JSONPath
+		extractedValue, err := utils.ExtractStringValueFromJsonpath(payload, params.JsonPath)
+		if err != nil {
+			return nil, 0, "Error extracting value from JSONPath", err
+		}
+
+		count, rawCount, err := measureText(cleanValue(extractedValue), params)
+		if err != nil {
+			return nil, 0, "Error measuring content length", err
+		}
+		return []measurement{{count: count, min: params.Min, max: params.Max, rawCount: rawCount}}, count, "", nil
+	}
+
+	matches, err := extractJSONPathMatches(payload, params.JsonPath)
+	if err != nil {
+		return nil, 0, "Error extracting value from JSONPath", err
+	}
+
+	elements := make([]measurement, 0, len(matches))
+	total := measurement{min: params.Min, max: params.Max, elements: len(matches), label: "elements"}
+	for _, match := range matches {
+		count, rawCount, err := measureText(cleanValue(match.value), params)
+		if err != nil {
+			return nil, 0, "Error measuring content length", err
+		}
+		elements = append(elements, measurement{count: count, min: params.Min, max: params.Max, path: match.path, rawCount: rawCount})
+		total.count += count
+		total.rawCount += rawCount
+	}
+
+	if len(elements) == 0 {
+		// Nothing matched, e.g. an empty messages array, so the content is empty
+		return []measurement{total}, 0, "", nil
+	}
+
+	switch params.Aggregate {
+	case AggregateEach:
+		return elements, total.count, "", nil
+	case AggregateMax:
+		longest := elements[0]
+		for _, m := range elements[1:] {
+			if m.count > longest.count {
+				longest = m
+			}
+		}
+		return []measurement{longest}, total.count, "", nil
+	default:
+		return []measurement{total}, total.count, "", nil
+	}
+}
+
+// cleanValue strips surrounding quotes and whitespace from an extracted value
+func cleanValue(value string) string {
+	value = textCleanRegexCompiled.ReplaceAllString(value, "")
+	return strings.TrimSpace(value)
+}
+
+// buildErrorResponse builds an error response for both request and response phases.
+// violation is the failed measurement, nil if the content could not be measured.
+func (p *ContentLengthGuardrailPolicy) buildErrorResponse(reason string, validationError error, isResponse bool, showAssessment bool, min, max int, unit string, violation *measurement) interface{} {
+	assessment := p.buildAssessmentObject(reason, validationError, isResponse, showAssessment, min, max, unit, violation)
+
+	if p.grpcEnabled {
+		return p.buildGRPCErrorResponse(assessment, isResponse)
+	}
 
 	responseBody := map[string]interface{}{
 		"type":    "CONTENT_LENGTH_GUARDRAIL",
@@ -270,8 +835,65 @@ func (p *ContentLengthGuardrailPolicy) buildErrorResponse(reason string, validat
 	}
 }
 
+// buildGRPCErrorResponse builds a gRPC error response carrying the assessment in grpc-message.
+// gRPC always uses HTTP 200; the outcome is carried by grpc-status.
+func (p *ContentLengthGuardrailPolicy) buildGRPCErrorResponse(assessment map[string]interface{}, isResponse bool) interface{} {
+	message := fmt.Sprintf("%v", assessment["actionReason"])
+	if assessments, ok := assessment["assessments"]; ok {
+		message = fmt.Sprintf("%s %v", message, assessments)
+	}
+	headers := map[string]string{
+		"Content-Type": GRPCContentType,
+		"grpc-status":  strconv.Itoa(p.grpcStatus),
+		"grpc-message": encodeGRPCMessage(message),
+	}
+
+	if isResponse {
+		statusCode := 200
+		return policy.UpstreamResponseModifications{
+			StatusCode: &statusCode,
+			Body:       []byte{},
+			SetHeaders: headers,
+		}
+	}
+
+	return policy.ImmediateResponse{
+		StatusCode: 200,
+		Headers:    headers,
+	}
+}
+
+// withRequestStatus replaces the status of a request rejection built by buildErrorResponse.
+// gRPC rejections keep HTTP 200 and get the closest grpc-status instead.
+func (p *ContentLengthGuardrailPolicy) withRequestStatus(resp interface{}, statusCode int) policy.RequestAction {
+	immediate, ok := resp.(policy.ImmediateResponse)
+	if !ok {
+		return resp.(policy.RequestAction)
+	}
+	if !p.grpcEnabled {
+		immediate.StatusCode = statusCode
+	} else if grpcStatus, ok := grpcStatusMapping[statusCode]; ok {
+		immediate.Headers["grpc-status"] = strconv.Itoa(grpcStatus)
+	}
+	return immediate
+}
+
+// encodeGRPCMessage percent-encodes grpc-message as required by the gRPC HTTP/2 protocol
+func encodeGRPCMessage(message string) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
 // buildAssessmentObject builds the assessment object
-func (p *ContentLengthGuardrailPolicy) buildAssessmentObject(reason string, validationError error, isResponse bool, showAssessment bool, min, max int) map[string]interface{} {
+func (p *ContentLengthGuardrailPolicy) buildAssessmentObject(reason string, validationError error, isResponse bool, showAssessment bool, min, max int, unit string, violation *measurement) map[string]interface{} {
 	assessment := map[string]interface{}{
 		"action":               "GUARDRAIL_INTERVENED",
 		"interveningGuardrail": "ContentLengthGuardrail",
@@ -292,12 +914,54 @@ func (p *ContentLengthGuardrailPolicy) buildAssessmentObject(reason string, vali
 	if showAssessment {
 		if validationError != nil {
 			assessment["assessments"] = validationError.Error()
+		} else if violation != nil && violation.ratio != nil {
+			assessment["assessments"] = fmt.Sprintf("Violation of response to request length ratio detected. Expected the response length to be %s the request length. Request length: %d %s, response length: %d %s.",
+				violation.ratio.describe(), violation.requestLength, unit, violation.count, unit)
+			assessment["requestLength"] = violation.requestLength
+			assessment["responseLength"] = violation.count
+		} else if violation != nil && violation.partCount {
+			if violation.part.name != "" {
+				assessment["assessments"] = fmt.Sprintf("Violation of form part count detected. Expected at most %d parts named %q but found %d.", violation.max, violation.part.name, violation.count)
+			} else {
+				assessment["assessments"] = fmt.Sprintf("Violation of form part count detected. Expected at most %d parts but found %d.", violation.max, violation.count)
+			}
+			assessment["partCount"] = violation.count
+		} else if violation != nil && violation.budget != nil {
+			assessment["assessments"] = fmt.Sprintf("Session length budget exceeded. The request has a length of %d %s but only %d of the %d %s budget remain in the current %s window.",
+				violation.count, unit, violation.remaining, violation.budget.limit, unit, violation.budget.window)
+			assessment["remainingBudget"] = violation.remaining
 		} else {
 			var assessmentMessage string
 			if strings.Contains(reason, "excluded range") {
-				assessmentMessage = fmt.Sprintf("Violation of content length detected. Expected content length to be outside the range of %d to %d bytes.", min, max)
+				assessmentMessage = fmt.Sprintf("Violation of content length detected. Expected content length to be outside the range of %d to %d %s.", min, max, unit)
 			} else {
-				assessmentMessage = fmt.Sprintf("Violation of content length detected. Expected content length to be between %d and %d bytes.", min, max)
+				assessmentMessage = fmt.Sprintf("Violation of content length detected. Expected content length to be between %d and %d %s.", min, max, unit)
+			}
+			if violation != nil && violation.part != nil {
+				violatingPart := map[string]interface{}{
+					"name":   violation.part.name,
+					"length": violation.count,
+				}
+				if violation.part.fileName != "" {
+					assessmentMessage = fmt.Sprintf("%s File part %q (%s) has a length of %d %s.", assessmentMessage, violation.part.name, violation.part.fileName, violation.count, unit)
+					violatingPart["fileName"] = violation.part.fileName
+				} else {
+					assessmentMessage = fmt.Sprintf("%s Form field %q has a length of %d %s.", assessmentMessage, violation.part.name, violation.count, unit)
+				}
+				assessment["violatingPart"] = violatingPart
+			} else if violation != nil && violation.path != "" {
+				assessmentMessage = fmt.Sprintf("%s Element %s has a length of %d %s.", assessmentMessage, violation.path, violation.count, unit)
+				assessment["violatingElement"] = map[string]interface{}{
+					"path":   violation.path,
+					"length": violation.count,
+				}
+			} else if violation != nil && violation.label != "" {
+				assessmentMessage = fmt.Sprintf("%s Total length of %d %s is %d %s.", assessmentMessage, violation.elements, violation.label, violation.count, unit)
+			}
+			if violation != nil && p.normalizes(isResponse) {
+				assessmentMessage = fmt.Sprintf("%s Raw length: %d %s, normalized length: %d %s.", assessmentMessage, violation.rawCount, unit, violation.count, unit)
+				assessment["rawLength"] = violation.rawCount
+				assessment["normalizedLength"] = violation.count
 			}
 			assessment["assessments"] = assessmentMessage
 		}
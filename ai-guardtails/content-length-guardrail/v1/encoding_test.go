@@ -0,0 +1,243 @@
+package contentlengthguardrail
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+// encode applies the content codings to the body in order
+func encode(t *testing.T, body []byte, codings ...string) []byte {
+	t.Helper()
+	for _, coding := range codings {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch coding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "raw-deflate":
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		case "zstd":
+			var err error
+			if w, err = zstd.NewWriter(&buf); err != nil {
+				t.Fatalf("zstd.NewWriter: %v", err)
+			}
+		default:
+			t.Fatalf("unknown coding %q", coding)
+		}
+		w.Write(body)
+		if err := w.Close(); err != nil {
+			t.Fatalf("closing %s writer: %v", coding, err)
+		}
+		body = buf.Bytes()
+	}
+	return body
+}
+
+func TestDecompressBody(t *testing.T) {
+	plain := []byte(`{"prompt": "hello world"}`)
+
+	tests := []struct {
+		name    string
+		encoded []byte
+		codings []string
+	}{
+		{name: "gzip", encoded: encode(t, plain, "gzip"), codings: []string{"gzip"}},
+		{name: "x-gzip", encoded: encode(t, plain, "gzip"), codings: []string{"x-gzip"}},
+		{name: "deflate", encoded: encode(t, plain, "deflate"), codings: []string{"deflate"}},
+		{name: "raw deflate", encoded: encode(t, plain, "raw-deflate"), codings: []string{"deflate"}},
+		{name: "brotli", encoded: encode(t, plain, "br"), codings: []string{"br"}},
+		{name: "zstd", encoded: encode(t, plain, "zstd"), codings: []string{"zstd"}},
+		{name: "stacked codings", encoded: encode(t, plain, "gzip", "br"), codings: []string{"gzip", "br"}},
+		{name: "no codings", encoded: plain, codings: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := decompressionLimits{maxSize: DefaultMaxDecompressedSize, maxRatio: DefaultMaxDecompressionRatio}
+			got, err := decompressBody(tt.encoded, tt.codings, limits)
+			if err != nil {
+				t.Fatalf("decompressBody: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decompressBody = %q, want %q", got, plain)
+			}
+		})
+	}
+}
+
+func TestDecompressionLimits(t *testing.T) {
+	// 1 MiB of zeros compresses to about 1 KiB
+	bomb := encode(t, make([]byte, 1<<20), "gzip")
+
+	tests := []struct {
+		name    string
+		body    []byte
+		codings []string
+		limits  decompressionLimits
+		wantErr string
+	}{
+		{
+			name:    "within limits",
+			body:    bomb,
+			codings: []string{"gzip"},
+			limits:  decompressionLimits{maxSize: 2 << 20, maxRatio: 10000},
+		},
+		{
+			name:    "above size limit",
+			body:    bomb,
+			codings: []string{"gzip"},
+			limits:  decompressionLimits{maxSize: 1 << 19, maxRatio: 10000},
+			wantErr: "exceeds the limit",
+		},
+		{
+			name:    "above ratio limit",
+			body:    bomb,
+			codings: []string{"gzip"},
+			limits:  decompressionLimits{maxSize: 2 << 20, maxRatio: 100},
+			wantErr: "exceeds the limit",
+		},
+		{
+			name:    "limit applies to each stacked coding",
+			body:    encode(t, make([]byte, 1<<20), "gzip", "gzip"),
+			codings: []string{"gzip", "gzip"},
+			limits:  decompressionLimits{maxSize: 1 << 19, maxRatio: 10000},
+			wantErr: "exceeds the limit",
+		},
+		{
+			name:    "unsupported coding",
+			body:    []byte("data"),
+			codings: []string{"compress"},
+			limits:  decompressionLimits{maxSize: 2 << 20, maxRatio: 100},
+			wantErr: "unsupported content encoding",
+		},
+		{
+			name:    "corrupt body",
+			body:    []byte("this body is not gzip encoded"),
+			codings: []string{"gzip"},
+			limits:  decompressionLimits{maxSize: 2 << 20, maxRatio: 100},
+			wantErr: "invalid header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decompressBody(tt.body, tt.codings, tt.limits)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("decompressBody: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContentEncodings(t *testing.T) {
+	headers := policy.NewHeaders(map[string][]string{"content-encoding": {"GZIP, identity", " br "}})
+	got := contentEncodings(headers)
+	if strings.Join(got, ",") != "gzip,br" {
+		t.Errorf("contentEncodings = %v, want [gzip br]", got)
+	}
+}
+
+func TestEncodedRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          []byte
+		decompression map[string]interface{}
+		status        int
+	}{
+		{name: "decoded body within range", body: encode(t, []byte("one two three"), "gzip"), status: 0},
+		{name: "decoded body above range", body: encode(t, []byte("one two three four five six"), "gzip"), status: GuardrailErrorCode},
+		{
+			name:          "decompression bomb rejected",
+			body:          encode(t, bytes.Repeat([]byte("word "), 1<<16), "gzip"),
+			decompression: map[string]interface{}{"maxSize": 1024},
+			status:        GuardrailErrorCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				"request": map[string]interface{}{"min": 1, "max": 5, "unit": "words"},
+			}
+			if tt.decompression != nil {
+				params["decompression"] = tt.decompression
+			}
+			p := newTestPolicy(t, params)
+			headers := map[string][]string{"content-encoding": {"gzip"}}
+			if got := requestStatus(t, runRequest(p, headers, string(tt.body), nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestEncodedRequestByteRange(t *testing.T) {
+	// 26 bytes decoded, far fewer on the wire
+	body := encode(t, []byte(strings.Repeat("a", 26)), "gzip")
+
+	tests := []struct {
+		name      string
+		onMissing string
+		max       int
+		status    int
+	}{
+		{name: "decoded body within max", max: 26, status: 0},
+		{name: "decoded body above max", max: 25, status: GuardrailErrorCode},
+		{name: "decoded body above max with reject", onMissing: OnMissingContentLengthReject, max: 25, status: GuardrailErrorCode},
+		{name: "decoded body above max with allow", onMissing: OnMissingContentLengthAllow, max: 25, status: GuardrailErrorCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := map[string]interface{}{"min": 0, "max": tt.max}
+			if tt.onMissing != "" {
+				request["onMissingContentLength"] = tt.onMissing
+			}
+			p := newTestPolicy(t, map[string]interface{}{"request": request})
+			headers := map[string][]string{"content-encoding": {"gzip"}}
+			if got := requestStatus(t, runRequest(p, headers, string(body), nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestEncodedResponseRewriteDropsEncoding(t *testing.T) {
+	p := newTestPolicy(t, map[string]interface{}{
+		"response": map[string]interface{}{"min": 1, "max": 2, "unit": "words"},
+	})
+	headers := map[string][]string{"content-encoding": {"gzip"}}
+	action := runResponse(p, headers, string(encode(t, []byte("one two three"), "gzip")), nil)
+	if got := responseStatus(t, action); got != GuardrailErrorCode {
+		t.Fatalf("status = %d, want %d", got, GuardrailErrorCode)
+	}
+	mods := action.(policy.UpstreamResponseModifications)
+	removed := false
+	for _, name := range mods.RemoveHeaders {
+		if strings.EqualFold(name, ContentEncodingHeader) {
+			removed = true
+		}
+	}
+	if !removed {
+		t.Errorf("removed headers = %v, want %s removed", mods.RemoveHeaders, ContentEncodingHeader)
+	}
+}
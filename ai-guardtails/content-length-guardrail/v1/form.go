@@ -0,0 +1,278 @@
+package contentlengthguardrail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	FormContentTypeMultipart  = "multipart/form-data"
+	FormContentTypeURLEncoded = "application/x-www-form-urlencoded"
+)
+
+// formConfig applies length and count limits to the parts of form bodies, in addition to
+// the limits on the whole encoded body
+type formConfig struct {
+	limits   []formLimit
+	maxParts int
+	hasTotal bool
+	totalMin int
+	totalMax int
+}
+
+// formLimit limits the fields or file parts of a name
+type formLimit struct {
+	name     string
+	hasRange bool
+	min      int
+	max      int
+	maxCount int
+}
+
+// formPart is a form field, or a file part if it has a file name
+type formPart struct {
+	name     string
+	fileName string
+	content  []byte
+}
+
+// parseFormConfig parses and validates the form configuration
+func parseFormConfig(params map[string]interface{}) (*formConfig, error) {
+	c := &formConfig{}
+
+	if maxPartsRaw, ok := params["maxParts"]; ok {
+		maxParts, err := extractInt(maxPartsRaw)
+		if err != nil || maxParts <= 0 {
+			return nil, fmt.Errorf("'maxParts' must be an integer greater than 0")
+		}
+		c.maxParts = maxParts
+	}
+
+	if totalRaw, ok := params["total"]; ok {
+		totalParams, ok := totalRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'total' must be an object")
+		}
+		min, max, err := parseRange(totalParams)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'total': %w", err)
+		}
+		c.hasTotal = true
+		c.totalMin = min
+		c.totalMax = max
+	}
+
+	if limitsRaw, ok := params["parts"]; ok {
+		limitsList, ok := limitsRaw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'parts' must be an array")
+		}
+		names := make(map[string]bool)
+		for i, limitRaw := range limitsList {
+			limitParams, ok := limitRaw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'parts[%d]' must be an object", i)
+			}
+			limit, err := parseFormLimit(limitParams)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'parts[%d]': %w", i, err)
+			}
+			if names[limit.name] {
+				return nil, fmt.Errorf("duplicate part name %q", limit.name)
+			}
+			names[limit.name] = true
+			c.limits = append(c.limits, limit)
+		}
+	}
+
+	if len(c.limits) == 0 && c.maxParts == 0 && !c.hasTotal {
+		return nil, fmt.Errorf("at least one of 'parts', 'maxParts' or 'total' is required")
+	}
+
+	return c, nil
+}
+
+// parseFormLimit parses and validates the limits of a part name
+func parseFormLimit(params map[string]interface{}) (formLimit, error) {
+	limit := formLimit{}
+
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return limit, fmt.Errorf("'name' is required and must be a non-empty string")
+	}
+	limit.name = name
+
+	_, hasMin := params["min"]
+	_, hasMax := params["max"]
+	if hasMin || hasMax {
+		min, max, err := parseRange(params)
+		if err != nil {
+			return limit, err
+		}
+		limit.hasRange = true
+		limit.min = min
+		limit.max = max
+	}
+
+	if maxCountRaw, ok := params["maxCount"]; ok {
+		maxCount, err := extractInt(maxCountRaw)
+		if err != nil || maxCount <= 0 {
+			return limit, fmt.Errorf("'maxCount' must be an integer greater than 0")
+		}
+		limit.maxCount = maxCount
+	}
+
+	if !limit.hasRange && limit.maxCount == 0 {
+		return limit, fmt.Errorf("at least one of 'min'/'max' or 'maxCount' is required")
+	}
+
+	return limit, nil
+}
+
+// formMediaType returns the form media type and its parameters, or an empty media type if
+// the request is not a form
+func formMediaType(headers *policy.Headers) (string, map[string]string) {
+	values := headers.Get("content-type")
+	if len(values) == 0 {
+		return "", nil
+	}
+	mediaType, mediaParams, err := mime.ParseMediaType(values[0])
+	if err != nil || (mediaType != FormContentTypeMultipart && mediaType != FormContentTypeURLEncoded) {
+		return "", nil
+	}
+	return mediaType, mediaParams
+}
+
+// parseForm splits a form body into its parts, in body order
+func parseForm(body []byte, mediaType string, mediaParams map[string]string) ([]formPart, error) {
+	if mediaType == FormContentTypeURLEncoded {
+		return parseURLEncodedForm(body)
+	}
+
+	boundary := mediaParams["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart body has no boundary")
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var parts []formPart
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, formPart{name: part.FormName(), fileName: part.FileName(), content: content})
+	}
+}
+
+// parseURLEncodedForm splits an application/x-www-form-urlencoded body into its fields.
+// Unlike url.ParseQuery it keeps the body order.
+func parseURLEncodedForm(body []byte) ([]formPart, error) {
+	var parts []formPart
+	for _, pair := range strings.Split(string(body), "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, formPart{name: name, content: []byte(value)})
+	}
+	return parts, nil
+}
+
+// validateForm validates the parts of a form body, returning nil if they pass. Fields are
+// measured in the configured unit and file parts in bytes; the total is the size of all part
+// contents in bytes.
+func (p *ContentLengthGuardrailPolicy) validateForm(payload []byte, mediaType string, mediaParams map[string]string, params ContentLengthGuardrailPolicyParams) policy.RequestAction {
+	form := params.Form
+	byteParams := params
+	byteParams.Unit = UnitBytes
+	byteParams.Normalize = nil
+
+	parts, err := parseForm(payload, mediaType, mediaParams)
+	if err != nil {
+		return p.buildErrorResponse("Error parsing form body", err, false, params.ShowAssessment, params.Min, params.Max, params.Unit, nil).(policy.RequestAction)
+	}
+
+	if form.maxParts > 0 && len(parts) > form.maxParts {
+		return p.buildPartCountResponse(len(parts), form.maxParts, "", params)
+	}
+
+	for _, limit := range form.limits {
+		count := 0
+		for i := range parts {
+			part := &parts[i]
+			if part.name != limit.name {
+				continue
+			}
+			count++
+			if !limit.hasRange {
+				continue
+			}
+
+			partParams := params
+			m := measurement{min: limit.min, max: limit.max, part: part}
+			if part.fileName != "" {
+				partParams = byteParams
+				m.count = len(part.content)
+				m.rawCount = m.count
+			} else {
+				m.count, m.rawCount, err = measureText(strings.TrimSpace(string(part.content)), params)
+				if err != nil {
+					return p.buildErrorResponse("Error measuring content length", err, false, params.ShowAssessment, params.Min, params.Max, params.Unit, nil).(policy.RequestAction)
+				}
+			}
+			if !passesRange(m.count, m.min, m.max, params.Invert) {
+				return p.buildViolationResponse(m, partParams, false).(policy.RequestAction)
+			}
+		}
+		if limit.maxCount > 0 && count > limit.maxCount {
+			return p.buildPartCountResponse(count, limit.maxCount, limit.name, params)
+		}
+	}
+
+	if form.hasTotal {
+		total := measurement{min: form.totalMin, max: form.totalMax, elements: len(parts), label: "form parts"}
+		for _, part := range parts {
+			total.count += len(part.content)
+		}
+		total.rawCount = total.count
+		if !passesRange(total.count, total.min, total.max, params.Invert) {
+			return p.buildViolationResponse(total, byteParams, false).(policy.RequestAction)
+		}
+	}
+
+	return nil
+}
+
+// buildPartCountResponse rejects a form with too many parts, of a name if given
+func (p *ContentLengthGuardrailPolicy) buildPartCountResponse(count, maxCount int, name string, params ContentLengthGuardrailPolicyParams) policy.RequestAction {
+	subject := "form parts"
+	if name != "" {
+		subject = fmt.Sprintf("form parts named %q", name)
+	}
+	reason := fmt.Sprintf("%d %s exceed the allowed count of %d", count, subject, maxCount)
+	return p.buildErrorResponse(reason, nil, false, params.ShowAssessment, params.Min, params.Max, params.Unit,
+		&measurement{count: count, max: maxCount, part: &formPart{name: name}, partCount: true}).(policy.RequestAction)
+}
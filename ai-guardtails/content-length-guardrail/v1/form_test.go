@@ -0,0 +1,145 @@
+package contentlengthguardrail
+
+import (
+	"bytes"
+	"mime/multipart"
+	"testing"
+)
+
+// multipartBody encodes fields and a file part named "file" as multipart/form-data
+func multipartBody(t *testing.T, fields map[string]string, file string) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			t.Fatalf("WriteField: %v", err)
+		}
+	}
+	if file != "" {
+		fw, err := w.CreateFormFile("file", "upload.txt")
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		fw.Write([]byte(file))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.String(), w.FormDataContentType()
+}
+
+func TestFormLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      map[string]interface{}
+		contentType string
+		body        string
+		status      int
+	}{
+		{
+			name: "field within range",
+			params: map[string]interface{}{"unit": "words", "form": map[string]interface{}{
+				"parts": []interface{}{map[string]interface{}{"name": "prompt", "min": 1, "max": 3}},
+			}},
+			contentType: FormContentTypeURLEncoded,
+			body:        "prompt=one+two+three&model=m",
+			status:      0,
+		},
+		{
+			name: "field above range",
+			params: map[string]interface{}{"unit": "words", "form": map[string]interface{}{
+				"parts": []interface{}{map[string]interface{}{"name": "prompt", "min": 1, "max": 2}},
+			}},
+			contentType: FormContentTypeURLEncoded,
+			body:        "prompt=one+two+three&model=m",
+			status:      GuardrailErrorCode,
+		},
+		{
+			name: "repeated field above count",
+			params: map[string]interface{}{"unit": "words", "form": map[string]interface{}{
+				"parts": []interface{}{map[string]interface{}{"name": "tag", "maxCount": 2}},
+			}},
+			contentType: FormContentTypeURLEncoded,
+			body:        "tag=a&tag=b&tag=c",
+			status:      GuardrailErrorCode,
+		},
+		{
+			name: "too many parts",
+			params: map[string]interface{}{"unit": "words", "form": map[string]interface{}{
+				"maxParts": 2,
+			}},
+			contentType: FormContentTypeURLEncoded,
+			body:        "a=1&b=2&c=3",
+			status:      GuardrailErrorCode,
+		},
+		{
+			name: "total part size above range",
+			params: map[string]interface{}{"unit": "words", "form": map[string]interface{}{
+				"total": map[string]interface{}{"min": 0, "max": 5},
+			}},
+			contentType: FormContentTypeURLEncoded,
+			body:        "a=123&b=456",
+			status:      GuardrailErrorCode,
+		},
+		{
+			name: "non-form body skips part checks",
+			params: map[string]interface{}{"unit": "words", "form": map[string]interface{}{
+				"maxParts": 1,
+			}},
+			contentType: "text/plain",
+			body:        "a=1&b=2&c=3",
+			status:      0,
+		},
+		{
+			name: "whole body checked with part checks",
+			params: map[string]interface{}{"min": 1, "max": 2, "unit": "words", "form": map[string]interface{}{
+				"parts": []interface{}{map[string]interface{}{"name": "prompt", "min": 1, "max": 10}},
+			}},
+			contentType: FormContentTypeURLEncoded,
+			body:        "prompt=one+two+three",
+			status:      GuardrailErrorCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPolicy(t, map[string]interface{}{"request": tt.params})
+			headers := map[string][]string{"content-type": {tt.contentType}}
+			if got := requestStatus(t, runRequest(p, headers, tt.body, nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestMultipartFileLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		max    int
+		status int
+	}{
+		{name: "file within byte range", file: "0123456789", max: 10, status: 0},
+		{name: "file above byte range", file: "0123456789", max: 9, status: GuardrailErrorCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// File parts are measured in bytes even though fields are measured in words
+			p := newTestPolicy(t, map[string]interface{}{
+				"request": map[string]interface{}{"unit": "words", "form": map[string]interface{}{
+					"parts": []interface{}{
+						map[string]interface{}{"name": "file", "min": 1, "max": tt.max},
+						map[string]interface{}{"name": "prompt", "min": 1, "max": 2},
+					},
+				}},
+			})
+			body, contentType := multipartBody(t, map[string]string{"prompt": "describe this"}, tt.file)
+			headers := map[string][]string{"content-type": {contentType}}
+			if got := requestStatus(t, runRequest(p, headers, body, nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
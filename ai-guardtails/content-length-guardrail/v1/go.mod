@@ -3,3 +3,13 @@ module github.com/renuka-fernando/api-platform-gateway-extensions/content-length
 go 1.23.0
 
 require github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.17.11
+	github.com/rivo/uniseg v0.4.7
+	github.com/tiktoken-go/tokenizer v0.7.0
+	golang.org/x/text v0.21.0
+)
+
+require github.com/dlclark/regexp2 v1.11.5 // indirect
@@ -0,0 +1,168 @@
+package contentlengthguardrail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// How lengths of values matched by a wildcard JSONPath are combined
+	AggregateSum  = "sum"
+	AggregateMax  = "max"
+	AggregateEach = "each"
+)
+
+// jsonPathSegmentRegex matches a path segment: an optional key followed by [*] or [N] selectors
+var jsonPathSegmentRegex = regexp.MustCompile(`^([^\[\]]*)((?:\[(?:\*|-?\d+)\])*)$`)
+
+var jsonPathSelectorRegex = regexp.MustCompile(`\[(\*|-?\d+)\]`)
+
+// errJSONPathMismatch is wrapped by errors for values the rest of the path does not apply to,
+// e.g. a missing key or an index out of range. Wildcards skip such values.
+var errJSONPathMismatch = errors.New("JSONPath does not apply")
+
+// jsonPathMatch is a string value matched by a JSONPath, with the concrete path it was found at
+type jsonPathMatch struct {
+	path  string
+	value string
+}
+
+// isWildcardJSONPath reports whether the JSONPath can match more than one value
+func isWildcardJSONPath(jsonPath string) bool {
+	return strings.Contains(jsonPath, "*")
+}
+
+// validateJSONPath checks the syntax of a JSONPath, so invalid paths fail when the policy is
+// configured rather than on each request
+func validateJSONPath(jsonPath string) error {
+	path := strings.TrimPrefix(strings.TrimPrefix(jsonPath, "$"), ".")
+	if path == "" {
+		return nil
+	}
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" || jsonPathSegmentRegex.FindStringSubmatch(segment) == nil {
+			return fmt.Errorf("invalid JSONPath segment %q in %s", segment, jsonPath)
+		}
+	}
+	return nil
+}
+
+// extractJSONPathMatches returns all string and number values matched by a JSONPath supporting
+// "*" and "[*]" wildcards, e.g. "$.messages[*].content". Object wildcards are visited in key
+// order so results are deterministic. Other value types, such as multimodal content parts,
+// are skipped. A wildcard over an empty array or object matches nothing, which is not an error.
+func extractJSONPathMatches(payload []byte, jsonPath string) ([]jsonPathMatch, error) {
+	var data interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(jsonPath, "$"), ".")
+	var segments []string
+	if path != "" {
+		segments = strings.Split(path, ".")
+	}
+
+	var matches []jsonPathMatch
+	if err := collectJSONPathMatches(data, segments, "$", &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// collectJSONPathMatches walks the remaining path segments, appending matched values
+func collectJSONPathMatches(current interface{}, segments []string, path string, matches *[]jsonPathMatch) error {
+	if len(segments) == 0 {
+		switch v := current.(type) {
+		case string:
+			*matches = append(*matches, jsonPathMatch{path: path, value: v})
+		case float64:
+			*matches = append(*matches, jsonPathMatch{path: path, value: strconv.FormatFloat(v, 'f', -1, 64)})
+		}
+		return nil
+	}
+
+	parts := jsonPathSegmentRegex.FindStringSubmatch(segments[0])
+	if parts == nil {
+		return errors.New("invalid JSONPath segment: " + segments[0])
+	}
+	key := parts[1]
+	var selectors []string
+	for _, selector := range jsonPathSelectorRegex.FindAllStringSubmatch(parts[2], -1) {
+		selectors = append(selectors, selector[1])
+	}
+
+	switch {
+	case key == "*":
+		node, ok := current.(map[string]interface{})
+		if !ok {
+			// A "*" segment on an array behaves like [*]
+			return collectJSONPathSelectors(current, append([]string{"*"}, selectors...), segments[1:], path, matches)
+		}
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// Like the SDK's "*", members the rest of the path does not apply to are skipped
+			err := collectJSONPathSelectors(node[k], selectors, segments[1:], path+"."+k, matches)
+			if err != nil && !errors.Is(err, errJSONPathMismatch) {
+				return err
+			}
+		}
+		return nil
+	case key != "":
+		node, ok := current.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: invalid structure for key: %s", errJSONPathMismatch, key)
+		}
+		val, exists := node[key]
+		if !exists {
+			return fmt.Errorf("%w: key not found: %s", errJSONPathMismatch, key)
+		}
+		return collectJSONPathSelectors(val, selectors, segments[1:], path+"."+key, matches)
+	default:
+		return collectJSONPathSelectors(current, selectors, segments[1:], path, matches)
+	}
+}
+
+// collectJSONPathSelectors applies [*] and [N] selectors before continuing with the next segments
+func collectJSONPathSelectors(current interface{}, selectors []string, segments []string, path string, matches *[]jsonPathMatch) error {
+	if len(selectors) == 0 {
+		return collectJSONPathMatches(current, segments, path, matches)
+	}
+
+	arr, ok := current.([]interface{})
+	if !ok {
+		return fmt.Errorf("%w: not an array: %s", errJSONPathMismatch, path)
+	}
+
+	if selectors[0] == "*" {
+		for i, item := range arr {
+			// Elements the rest of the path does not apply to, e.g. messages without content, are skipped
+			err := collectJSONPathSelectors(item, selectors[1:], segments, fmt.Sprintf("%s[%d]", path, i), matches)
+			if err != nil && !errors.Is(err, errJSONPathMismatch) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	idx, err := strconv.Atoi(selectors[0])
+	if err != nil {
+		return errors.New("invalid array index: " + selectors[0])
+	}
+	if idx < 0 {
+		idx = len(arr) + idx
+	}
+	if idx < 0 || idx >= len(arr) {
+		return fmt.Errorf("%w: array index out of range: %s", errJSONPathMismatch, selectors[0])
+	}
+	return collectJSONPathSelectors(arr[idx], selectors[1:], segments, fmt.Sprintf("%s[%d]", path, idx), matches)
+}
@@ -0,0 +1,189 @@
+package contentlengthguardrail
+
+import (
+	"reflect"
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+func TestExtractJSONPathMatches(t *testing.T) {
+	payload := []byte(`{
+		"messages": [
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": "hello"},
+			{"role": "assistant", "tool_calls": []},
+			{"role": "user", "content": 42}
+		],
+		"docs": {"b": {"text": "second"}, "a": {"text": "first"}, "c": {}},
+		"empty": []
+	}`)
+
+	tests := []struct {
+		name     string
+		jsonPath string
+		paths    []string
+		values   []string
+		wantErr  bool
+	}{
+		{
+			name:     "array wildcard skips elements without the key",
+			jsonPath: "$.messages[*].content",
+			paths:    []string{"$.messages[0].content", "$.messages[1].content", "$.messages[3].content"},
+			values:   []string{"be brief", "hello", "42"},
+		},
+		{
+			name:     "object wildcard in key order",
+			jsonPath: "$.docs.*.text",
+			paths:    []string{"$.docs.a.text", "$.docs.b.text"},
+			values:   []string{"first", "second"},
+		},
+		{
+			name:     "negative index",
+			jsonPath: "$.messages[-1].role",
+			paths:    []string{"$.messages[3].role"},
+			values:   []string{"user"},
+		},
+		{
+			name:     "no matches",
+			jsonPath: "$.messages[*].missing",
+		},
+		{
+			name:     "wildcard over an empty array",
+			jsonPath: "$.empty[*].content",
+		},
+		{
+			name:     "missing key before the wildcard",
+			jsonPath: "$.missing[*].content",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid segment under a wildcard",
+			jsonPath: "$.messages[*].content[[0",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := extractJSONPathMatches(payload, tt.jsonPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", matches)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractJSONPathMatches: %v", err)
+			}
+			var paths, values []string
+			for _, m := range matches {
+				paths = append(paths, m.path)
+				values = append(values, m.value)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("paths = %v, want %v", paths, tt.paths)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("values = %v, want %v", values, tt.values)
+			}
+		})
+	}
+}
+
+func TestWildcardAggregate(t *testing.T) {
+	// Contents of 3, 4 and 5 characters, 12 in total
+	body := `{"messages": [{"content": "abc"}, {"content": "abcd"}, {"content": "abcde"}]}`
+	empty := `{"messages": []}`
+
+	tests := []struct {
+		name      string
+		aggregate string
+		min       int
+		max       int
+		body      string
+		status    int
+	}{
+		{name: "sum within range", aggregate: AggregateSum, min: 0, max: 12, status: 0},
+		{name: "sum above range", aggregate: AggregateSum, min: 0, max: 11, status: GuardrailErrorCode},
+		{name: "max within range", aggregate: AggregateMax, min: 0, max: 5, status: 0},
+		{name: "max above range", aggregate: AggregateMax, min: 0, max: 4, status: GuardrailErrorCode},
+		{name: "each within range", aggregate: AggregateEach, min: 3, max: 5, status: 0},
+		{name: "each below range", aggregate: AggregateEach, min: 4, max: 5, status: GuardrailErrorCode},
+		{name: "empty array within range", aggregate: AggregateSum, min: 0, max: 5, body: empty, status: 0},
+		{name: "empty array below range", aggregate: AggregateSum, min: 1, max: 5, body: empty, status: GuardrailErrorCode},
+		{name: "empty array with max", aggregate: AggregateMax, min: 0, max: 5, body: empty, status: 0},
+		{name: "empty array with each", aggregate: AggregateEach, min: 0, max: 5, body: empty, status: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPolicy(t, map[string]interface{}{
+				"request": map[string]interface{}{
+					"min":       tt.min,
+					"max":       tt.max,
+					"unit":      "characters",
+					"jsonPath":  "$.messages[*].content",
+					"aggregate": tt.aggregate,
+				},
+			})
+			if tt.body == "" {
+				tt.body = body
+			}
+			if got := requestStatus(t, runRequest(p, nil, tt.body, nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestValidateJSONPath(t *testing.T) {
+	tests := []struct {
+		jsonPath string
+		wantErr  bool
+	}{
+		{jsonPath: "", wantErr: false},
+		{jsonPath: "$", wantErr: false},
+		{jsonPath: "$.messages[*].content", wantErr: false},
+		{jsonPath: "$.docs.*.text", wantErr: false},
+		{jsonPath: "$.items[-1][0]", wantErr: false},
+		{jsonPath: "messages", wantErr: false},
+		{jsonPath: "$.messages[*].content[[0", wantErr: true},
+		{jsonPath: "$.messages[x]", wantErr: true},
+		{jsonPath: "$.a..b", wantErr: true},
+		{jsonPath: "$.a.", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.jsonPath, func(t *testing.T) {
+			err := validateJSONPath(tt.jsonPath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateJSONPath(%q) = %v, want error %v", tt.jsonPath, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvalidJSONPathRejectedByGetPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{name: "jsonPath", params: map[string]interface{}{"min": 0, "max": 10, "jsonPath": "$.messages[x]"}},
+		{name: "messagesPath", params: map[string]interface{}{"chat": map[string]interface{}{
+			"messagesPath": "$.messages[",
+			"limits":       []interface{}{map[string]interface{}{"roles": []interface{}{"user"}, "min": 0, "max": 1}},
+		}}},
+		{name: "sessionJsonPath", params: map[string]interface{}{"budget": map[string]interface{}{
+			"limit": 10, "sessionJsonPath": "$..id",
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := GetPolicy(policy.PolicyMetadata{}, map[string]interface{}{"request": tt.params}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package contentlengthguardrail
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// Normalization steps applied to content before it is measured
+	NormalizeNFC                = "nfc"
+	NormalizeNFKC               = "nfkc"
+	NormalizeCollapseWhitespace = "collapseWhitespace"
+	NormalizeStripHTML          = "stripHtml"
+	NormalizeStripMarkdown      = "stripMarkdown"
+	NormalizeRemoveInvisible    = "removeInvisible"
+)
+
+var normalizers = map[string]func(string) string{
+	NormalizeNFC:                norm.NFC.String,
+	NormalizeNFKC:               norm.NFKC.String,
+	NormalizeCollapseWhitespace: collapseWhitespace,
+	NormalizeStripHTML:          stripHTML,
+	NormalizeStripMarkdown:      stripMarkdown,
+	NormalizeRemoveInvisible:    removeInvisible,
+}
+
+var (
+	htmlCommentRegex  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlScriptRegex   = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	htmlStyleRegex    = regexp.MustCompile(`(?is)<style\b.*?</style\s*>`)
+	htmlTagRegex      = regexp.MustCompile(`</?[a-zA-Z][^<>]*>`)
+	whitespaceRegex   = regexp.MustCompile(`\s+`)
+	mdCodeFenceRegex  = regexp.MustCompile("(?m)^[ \t]*(```|~~~).*$")
+	mdImageRegex      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRegex       = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdHeadingRegex    = regexp.MustCompile(`(?m)^[ \t]*#{1,6}[ \t]+`)
+	mdBlockquoteRegex = regexp.MustCompile(`(?m)^[ \t]*(>[ \t]?)+`)
+	mdListRegex       = regexp.MustCompile(`(?m)^[ \t]*([-*+]|\d+[.)])[ \t]+`)
+	mdRuleRegex       = regexp.MustCompile(`(?m)^[ \t]*([-*_][ \t]*){3,}$`)
+	mdEmphasisRegex   = regexp.MustCompile("(\\*{1,3}|_{1,3}|~~|`+)")
+)
+
+// parseNormalize parses and validates the normalize steps
+func parseNormalize(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'normalize' must be an array of strings")
+	}
+	steps := make([]string, 0, len(list))
+	seen := make(map[string]bool)
+	for _, stepRaw := range list {
+		step, ok := stepRaw.(string)
+		if !ok || normalizers[step] == nil {
+			return nil, fmt.Errorf("'normalize' steps must be one of %s, %s, %s, %s, %s or %s", NormalizeNFC, NormalizeNFKC,
+				NormalizeCollapseWhitespace, NormalizeStripHTML, NormalizeStripMarkdown, NormalizeRemoveInvisible)
+		}
+		if seen[step] {
+			return nil, fmt.Errorf("duplicate 'normalize' step %q", step)
+		}
+		seen[step] = true
+		steps = append(steps, step)
+	}
+	if seen[NormalizeNFC] && seen[NormalizeNFKC] {
+		return nil, fmt.Errorf("'normalize' cannot contain both %s and %s", NormalizeNFC, NormalizeNFKC)
+	}
+	return steps, nil
+}
+
+// normalizeText applies the normalization steps in order
+func normalizeText(text string, steps []string) string {
+	for _, step := range steps {
+		text = normalizers[step](text)
+	}
+	return text
+}
+
+// measureText measures text in the configured unit, after normalization if configured. It
+// also returns the length of the raw text, which equals the count without normalization.
+func measureText(text string, params ContentLengthGuardrailPolicyParams) (int, int, error) {
+	rawCount, err := countContent(text, params)
+	if err != nil || len(params.Normalize) == 0 {
+		return rawCount, rawCount, err
+	}
+	count, err := countContent(strings.TrimSpace(normalizeText(text, params.Normalize)), params)
+	return count, rawCount, err
+}
+
+// collapseWhitespace collapses runs of whitespace into a single space, or a single line break
+// if the run contains one, so line counts stay meaningful
+func collapseWhitespace(text string) string {
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllStringFunc(text, func(run string) string {
+		if strings.ContainsAny(run, "\n\r") {
+			return "\n"
+		}
+		return " "
+	}))
+}
+
+// stripHTML removes HTML comments, scripts, styles and tags, and decodes character references
+func stripHTML(text string) string {
+	text = htmlCommentRegex.ReplaceAllString(text, "")
+	text = htmlScriptRegex.ReplaceAllString(text, "")
+	text = htmlStyleRegex.ReplaceAllString(text, "")
+	text = htmlTagRegex.ReplaceAllString(text, "")
+	return html.UnescapeString(text)
+}
+
+// stripMarkdown removes Markdown syntax, keeping the text of links, images and code
+func stripMarkdown(text string) string {
+	text = mdCodeFenceRegex.ReplaceAllString(text, "")
+	text = mdImageRegex.ReplaceAllString(text, "$1")
+	text = mdLinkRegex.ReplaceAllString(text, "$1")
+	text = mdRuleRegex.ReplaceAllString(text, "")
+	text = mdHeadingRegex.ReplaceAllString(text, "")
+	text = mdBlockquoteRegex.ReplaceAllString(text, "")
+	text = mdListRegex.ReplaceAllString(text, "")
+	return mdEmphasisRegex.ReplaceAllString(text, "")
+}
+
+// removeInvisible removes zero-width and other format characters, variation selectors and
+// control characters other than whitespace
+func removeInvisible(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Variation_Selector, r) || (unicode.IsControl(r) && !unicode.IsSpace(r)) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// normalizes reports whether content of the phase is normalized before it is measured
+func (p *ContentLengthGuardrailPolicy) normalizes(isResponse bool) bool {
+	if isResponse {
+		return p.hasResponseParams && len(p.responseParams.Normalize) > 0
+	}
+	return p.hasRequestParams && len(p.requestParams.Normalize) > 0
+}
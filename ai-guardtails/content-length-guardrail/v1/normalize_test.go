@@ -0,0 +1,112 @@
+package contentlengthguardrail
+
+import (
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		steps []string
+		want  string
+	}{
+		{
+			name:  "nfc composes combining marks",
+			text:  "e\u0301",
+			steps: []string{NormalizeNFC},
+			want:  "\u00e9",
+		},
+		{
+			name:  "nfkc folds compatibility characters",
+			text:  "\uff21\ufb01",
+			steps: []string{NormalizeNFKC},
+			want:  "Afi",
+		},
+		{
+			name:  "collapse whitespace keeps line breaks",
+			text:  "  a \t b\n\n  c  ",
+			steps: []string{NormalizeCollapseWhitespace},
+			want:  "a b\nc",
+		},
+		{
+			name:  "strip html",
+			text:  "<p>a &amp; b</p><!-- note --><script>x()</script><style>p{}</style>",
+			steps: []string{NormalizeStripHTML},
+			want:  "a & b",
+		},
+		{
+			name:  "strip markdown",
+			text:  "# Title\n> **bold** and [link](http://x) ![alt](i.png)\n- `code`",
+			steps: []string{NormalizeStripMarkdown},
+			want:  "Title\nbold and link alt\ncode",
+		},
+		{
+			name:  "remove invisible",
+			text:  "a\u200bb\ufe0fc\x00d\n",
+			steps: []string{NormalizeRemoveInvisible},
+			want:  "abcd\n",
+		},
+		{
+			name:  "steps apply in order",
+			text:  "<b>a</b>\u200b   <i>b</i>",
+			steps: []string{NormalizeStripHTML, NormalizeRemoveInvisible, NormalizeCollapseWhitespace},
+			want:  "a b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.text, tt.steps); got != tt.want {
+				t.Errorf("normalizeText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNormalizeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "not an array", value: "nfc"},
+		{name: "unknown step", value: []interface{}{"lowercase"}},
+		{name: "duplicate step", value: []interface{}{"nfc", "nfc"}},
+		{name: "nfc with nfkc", value: []interface{}{"nfc", "nfkc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseNormalize(tt.value); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestNormalizedRange(t *testing.T) {
+	// 5 characters of text padded to 26 characters with markup and whitespace
+	body := "<p>hello</p>\u200b\u200b            "
+
+	tests := []struct {
+		name      string
+		normalize []interface{}
+		status    int
+	}{
+		{name: "raw length", normalize: nil, status: GuardrailErrorCode},
+		{name: "normalized length", normalize: []interface{}{"stripHtml", "removeInvisible", "collapseWhitespace"}, status: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{"min": 1, "max": 5, "unit": "characters"}
+			if tt.normalize != nil {
+				params["normalize"] = tt.normalize
+			}
+			p := newTestPolicy(t, map[string]interface{}{"request": params})
+			if got := requestStatus(t, runRequest(p, nil, body, nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package contentlengthguardrail
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	// MetadataKeyRequestLength holds the request length measured for the response ratio check
+	MetadataKeyRequestLength = "contentlengthguardrail:request_length"
+)
+
+// ratioConfig bounds the response length relative to the request length
+type ratioConfig struct {
+	min    float64
+	max    float64
+	hasMin bool
+	hasMax bool
+}
+
+// parseRatioConfig parses and validates the ratio configuration
+func parseRatioConfig(params map[string]interface{}) (*ratioConfig, error) {
+	c := &ratioConfig{}
+
+	if minRaw, ok := params["min"]; ok {
+		min, err := extractFloat(minRaw)
+		if err != nil || min < 0 {
+			return nil, fmt.Errorf("'min' must be a non-negative number")
+		}
+		c.min = min
+		c.hasMin = true
+	}
+
+	if maxRaw, ok := params["max"]; ok {
+		max, err := extractFloat(maxRaw)
+		if err != nil || max <= 0 {
+			return nil, fmt.Errorf("'max' must be a number greater than 0")
+		}
+		c.max = max
+		c.hasMax = true
+	}
+
+	if !c.hasMin && !c.hasMax {
+		return nil, fmt.Errorf("at least one of 'min' or 'max' is required")
+	}
+	if c.hasMin && c.hasMax && c.min > c.max {
+		return nil, fmt.Errorf("'min' cannot be greater than 'max'")
+	}
+
+	return c, nil
+}
+
+// extractFloat extracts a float from a number or numeric string
+func extractFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to float", value)
+	}
+}
+
+// passes reports whether the response length is within the ratio range of the request
+// length. An empty request only allows an empty response when a max is set.
+func (c *ratioConfig) passes(requestLength, responseLength int) bool {
+	var ratio float64
+	switch {
+	case requestLength > 0:
+		ratio = float64(responseLength) / float64(requestLength)
+	case responseLength > 0:
+		ratio = math.Inf(1)
+	default:
+		return true
+	}
+	if c.hasMin && ratio < c.min {
+		return false
+	}
+	if c.hasMax && ratio > c.max {
+		return false
+	}
+	return true
+}
+
+// describe describes the ratio range for assessment messages
+func (c *ratioConfig) describe() string {
+	switch {
+	case c.hasMin && c.hasMax:
+		return fmt.Sprintf("between %g and %g times", c.min, c.max)
+	case c.hasMin:
+		return fmt.Sprintf("at least %g times", c.min)
+	default:
+		return fmt.Sprintf("at most %g times", c.max)
+	}
+}
+
+// lengthRatio returns the response ratio configuration, nil if none
+func (p *ContentLengthGuardrailPolicy) lengthRatio() *ratioConfig {
+	if !p.hasResponseParams {
+		return nil
+	}
+	return p.responseParams.Ratio
+}
+
+// recordRequestLength records the measured request length for the response ratio check
+func recordRequestLength(metadata map[string]interface{}, length int) {
+	if metadata != nil {
+		metadata[MetadataKeyRequestLength] = length
+	}
+}
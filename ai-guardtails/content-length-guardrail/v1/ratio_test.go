@@ -0,0 +1,103 @@
+package contentlengthguardrail
+
+import (
+	"testing"
+)
+
+func TestRatioPasses(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         ratioConfig
+		requestLength  int
+		responseLength int
+		want           bool
+	}{
+		{name: "within max", config: ratioConfig{max: 2, hasMax: true}, requestLength: 10, responseLength: 20, want: true},
+		{name: "above max", config: ratioConfig{max: 2, hasMax: true}, requestLength: 10, responseLength: 21, want: false},
+		{name: "below min", config: ratioConfig{min: 0.5, hasMin: true}, requestLength: 10, responseLength: 4, want: false},
+		{name: "within min and max", config: ratioConfig{min: 0.5, max: 2, hasMin: true, hasMax: true}, requestLength: 10, responseLength: 5, want: true},
+		{name: "empty request and response", config: ratioConfig{max: 2, hasMax: true}, requestLength: 0, responseLength: 0, want: true},
+		{name: "empty request with a max", config: ratioConfig{max: 2, hasMax: true}, requestLength: 0, responseLength: 1, want: false},
+		{name: "empty request with only a min", config: ratioConfig{min: 0.5, hasMin: true}, requestLength: 0, responseLength: 1, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.passes(tt.requestLength, tt.responseLength); got != tt.want {
+				t.Errorf("passes(%d, %d) = %v, want %v", tt.requestLength, tt.responseLength, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  map[string]interface{}
+		body     string
+		response string
+		status   int
+	}{
+		{
+			name:     "response within ratio",
+			request:  map[string]interface{}{"min": 0, "max": 10, "unit": "words"},
+			body:     "one two",
+			response: "one two three four",
+			status:   0,
+		},
+		{
+			name:     "response above ratio",
+			request:  map[string]interface{}{"min": 0, "max": 10, "unit": "words"},
+			body:     "one two",
+			response: "one two three four five",
+			status:   GuardrailErrorCode,
+		},
+		{
+			name:     "ratio uses the truncated request length",
+			request:  map[string]interface{}{"min": 0, "max": 2, "unit": "words", "onViolation": "truncate"},
+			body:     "one two three four five",
+			response: "one two three four five",
+			status:   GuardrailErrorCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPolicy(t, map[string]interface{}{
+				"request": tt.request,
+				"response": map[string]interface{}{
+					"unit":  "words",
+					"ratio": map[string]interface{}{"max": 2},
+				},
+			})
+			metadata := map[string]interface{}{}
+			if got := requestStatus(t, runRequest(p, nil, tt.body, metadata)); got != 0 {
+				t.Fatalf("request status = %d, want 0", got)
+			}
+			if got := responseStatus(t, runResponse(p, map[string][]string{}, tt.response, metadata)); got != tt.status {
+				t.Errorf("response status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestParseRatioConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{name: "no bounds", params: map[string]interface{}{}},
+		{name: "negative min", params: map[string]interface{}{"min": -1}},
+		{name: "zero max", params: map[string]interface{}{"max": 0}},
+		{name: "min above max", params: map[string]interface{}{"min": 3, "max": 2}},
+		{name: "non-numeric max", params: map[string]interface{}{"max": "twice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseRatioConfig(tt.params); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package contentlengthguardrail
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	EventStreamContentType = "text/event-stream"
+	// EventStreamDone is the data OpenAI sends to end a stream
+	EventStreamDone = "[DONE]"
+)
+
+// sseEvent is a dispatched server-sent event
+type sseEvent struct {
+	event string
+	data  string
+}
+
+// streamChunk is the subset of OpenAI and Anthropic streaming chunks carrying assistant text
+type streamChunk struct {
+	// OpenAI chat completion chunk
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+
+	// Anthropic message stream event
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// isEventStream reports whether the response is a server-sent event stream. Without a
+// content type, streams are recognised by their first field.
+func isEventStream(headers *policy.Headers, body []byte) bool {
+	if values := headers.Get("content-type"); len(values) > 0 {
+		mediaType, _, _ := strings.Cut(values[0], ";")
+		return strings.EqualFold(strings.TrimSpace(mediaType), EventStreamContentType)
+	}
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return bytes.HasPrefix(trimmed, []byte("data:")) || bytes.HasPrefix(trimmed, []byte("event:"))
+}
+
+// parseEventStream parses a buffered event stream into its events
+func parseEventStream(body []byte) []sseEvent {
+	text := strings.ReplaceAll(string(body), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var events []sseEvent
+	var current sseEvent
+	var data []string
+	dispatch := func() {
+		if data != nil {
+			current.data = strings.Join(data, "\n")
+			events = append(events, current)
+		}
+		current = sseEvent{}
+		data = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			dispatch()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comment, e.g. a keep-alive
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			current.event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	dispatch()
+
+	return events
+}
+
+// reassembleEventStreamText reconstructs the assistant text from OpenAI choices[].delta.content
+// chunks and Anthropic content_block_delta text deltas. Texts of multiple OpenAI choices are
+// joined in index order. Events that are not JSON, such as [DONE] or pings, are skipped.
+func reassembleEventStreamText(body []byte) string {
+	var anthropicText strings.Builder
+	choiceTexts := make(map[int]*strings.Builder)
+
+	for _, event := range parseEventStream(body) {
+		if event.data == EventStreamDone {
+			continue
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(event.data), &chunk); err != nil {
+			continue
+		}
+
+		if chunk.Type == "content_block_delta" && chunk.Delta.Type == "text_delta" {
+			anthropicText.WriteString(chunk.Delta.Text)
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			sb, ok := choiceTexts[choice.Index]
+			if !ok {
+				sb = &strings.Builder{}
+				choiceTexts[choice.Index] = sb
+			}
+			sb.WriteString(choice.Delta.Content)
+		}
+	}
+
+	if len(choiceTexts) == 0 {
+		return anthropicText.String()
+	}
+	indexes := make([]int, 0, len(choiceTexts))
+	for index := range choiceTexts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	texts := make([]string, 0, len(indexes)+1)
+	for _, index := range indexes {
+		texts = append(texts, choiceTexts[index].String())
+	}
+	if anthropicText.Len() > 0 {
+		texts = append(texts, anthropicText.String())
+	}
+	return strings.Join(texts, "\n")
+}
+
+// validateEventStream applies the response length check to the text reassembled from an
+// event stream. A stream cannot be cut without breaking its framing, so violations are
+// always rejected, as an SSE error event the client's stream parser can read.
+func (p *ContentLengthGuardrailPolicy) validateEventStream(payload []byte, params ContentLengthGuardrailPolicyParams, metadata map[string]interface{}) policy.ResponseAction {
+	params.JsonPath = ""
+	params.OnViolation = OnViolationReject
+	// Streamed responses carry no messages for chat role limits
+	params.Chat = nil
+	if !params.HasRange && params.Ratio == nil {
+		return policy.UpstreamResponseModifications{}
+	}
+
+	text := reassembleEventStreamText(payload)
+	action := p.validatePayload([]byte(text), params, true, metadata).(policy.ResponseAction)
+
+	mods, ok := action.(policy.UpstreamResponseModifications)
+	if !ok || mods.StatusCode == nil || p.grpcEnabled {
+		return action
+	}
+	mods.Body = eventStreamErrorEvent(mods.Body)
+	mods.SetHeaders = map[string]string{
+		"Content-Type": EventStreamContentType,
+	}
+	return mods
+}
+
+// eventStreamErrorEvent wraps a JSON error body in an SSE error event
+func eventStreamErrorEvent(body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("event: error\ndata: ")
+	buf.Write(body)
+	buf.WriteString("\n\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,132 @@
+package contentlengthguardrail
+
+import (
+	"strings"
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+func TestIsEventStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		body    string
+		want    bool
+	}{
+		{name: "content type", headers: map[string][]string{"content-type": {"text/event-stream"}}, want: true},
+		{name: "content type with parameters", headers: map[string][]string{"content-type": {"Text/Event-Stream; charset=utf-8"}}, want: true},
+		{name: "json content type", headers: map[string][]string{"content-type": {"application/json"}}, body: "data: {}", want: false},
+		{name: "sniffed data field", body: "\n\ndata: {}\n\n", want: true},
+		{name: "sniffed event field", body: "event: ping\ndata: {}\n\n", want: true},
+		{name: "json body", body: `{"data": "x"}`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.headers == nil {
+				tt.headers = map[string][]string{}
+			}
+			if got := isEventStream(policy.NewHeaders(tt.headers), []byte(tt.body)); got != tt.want {
+				t.Errorf("isEventStream = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReassembleEventStreamText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "openai deltas",
+			body: "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			want: "Hello",
+		},
+		{
+			name: "openai choices in index order",
+			body: "data: {\"choices\":[{\"index\":1,\"delta\":{\"content\":\"second\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"first\"}}]}\n\n",
+			want: "first\nsecond",
+		},
+		{
+			name: "anthropic text deltas",
+			body: "event: message_start\ndata: {\"type\":\"message_start\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi \"}}\n\n" +
+				"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"there\"}}\n\n",
+			want: "Hi there",
+		},
+		{
+			name: "crlf framing and comments",
+			body: ": keep-alive\r\ndata: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"}}]}\r\n\r\n",
+			want: "ok",
+		},
+		{
+			name: "non-json events",
+			body: "data: not json\n\n",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reassembleEventStreamText([]byte(tt.body)); got != tt.want {
+				t.Errorf("reassembleEventStreamText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventStreamResponse(t *testing.T) {
+	stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"one two \"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"three four\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+	headers := map[string][]string{"content-type": {"text/event-stream"}}
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		status int
+	}{
+		{
+			name:   "reassembled text within range",
+			params: map[string]interface{}{"min": 1, "max": 4, "unit": "words"},
+			status: 0,
+		},
+		{
+			name:   "reassembled text above range",
+			params: map[string]interface{}{"min": 1, "max": 3, "unit": "words"},
+			status: GuardrailErrorCode,
+		},
+		{
+			name:   "truncation falls back to rejection",
+			params: map[string]interface{}{"min": 1, "max": 3, "unit": "words", "onViolation": "truncate"},
+			status: GuardrailErrorCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPolicy(t, map[string]interface{}{"response": tt.params})
+			action := runResponse(p, headers, stream, nil)
+			if got := responseStatus(t, action); got != tt.status {
+				t.Fatalf("status = %d, want %d", got, tt.status)
+			}
+			if tt.status == 0 {
+				return
+			}
+			mods := action.(policy.UpstreamResponseModifications)
+			if !strings.HasPrefix(string(mods.Body), "event: error\ndata: {") {
+				t.Errorf("body = %q, want an SSE error event", mods.Body)
+			}
+			if got := mods.SetHeaders["Content-Type"]; got != EventStreamContentType {
+				t.Errorf("content type = %q, want %q", got, EventStreamContentType)
+			}
+		})
+	}
+}
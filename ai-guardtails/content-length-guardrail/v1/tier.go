@@ -0,0 +1,228 @@
+package contentlengthguardrail
+
+import (
+	"fmt"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	MetadataKeyAuthUser  = "auth.username"
+	MetadataKeyAuthRoles = "auth.roles"
+)
+
+// tierConfig selects per-consumer length ranges. Lookups are resolved into maps at
+// GetPolicy time so requests only do map reads.
+type tierConfig struct {
+	tiers            []tier
+	byConsumer       map[string]int
+	byRole           map[string]int
+	byHeaderValue    map[string]int
+	byName           map[string]int
+	header           string
+	rolesMetadataKey string
+	// tierMetadataKey is the metadata key an authentication policy sets to the tier name
+	tierMetadataKey string
+}
+
+// tier is a named length range
+type tier struct {
+	name string
+	min  int
+	max  int
+}
+
+// parseTierConfig parses and validates the tiers, tierHeader, tierMetadataKey and rolesMetadataKey parameters
+func parseTierConfig(params map[string]interface{}) (*tierConfig, error) {
+	c := &tierConfig{
+		byConsumer:       make(map[string]int),
+		byRole:           make(map[string]int),
+		byHeaderValue:    make(map[string]int),
+		byName:           make(map[string]int),
+		rolesMetadataKey: MetadataKeyAuthRoles,
+	}
+
+	if headerRaw, ok := params["tierHeader"]; ok {
+		header, ok := headerRaw.(string)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("'tierHeader' must be a non-empty string")
+		}
+		c.header = strings.ToLower(header)
+	}
+
+	if rolesKeyRaw, ok := params["rolesMetadataKey"]; ok {
+		rolesKey, ok := rolesKeyRaw.(string)
+		if !ok || rolesKey == "" {
+			return nil, fmt.Errorf("'rolesMetadataKey' must be a non-empty string")
+		}
+		c.rolesMetadataKey = rolesKey
+	}
+
+	if tierKeyRaw, ok := params["tierMetadataKey"]; ok {
+		tierKey, ok := tierKeyRaw.(string)
+		if !ok || tierKey == "" {
+			return nil, fmt.Errorf("'tierMetadataKey' must be a non-empty string")
+		}
+		c.tierMetadataKey = tierKey
+	}
+
+	tiersList, ok := params["tiers"].([]interface{})
+	if !ok || len(tiersList) == 0 {
+		return nil, fmt.Errorf("'tiers' must be a non-empty array")
+	}
+	names := make(map[string]bool)
+	for i, tierRaw := range tiersList {
+		tierParams, ok := tierRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'tiers[%d]' must be an object", i)
+		}
+
+		name, ok := tierParams["name"].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("'tiers[%d].name' is required and must be a non-empty string", i)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate tier name %q", name)
+		}
+		names[name] = true
+		c.byName[name] = i
+
+		min, max, err := parseRange(tierParams)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier %q: %w", name, err)
+		}
+		c.tiers = append(c.tiers, tier{name: name, min: min, max: max})
+
+		selectors := 0
+		for _, selector := range []struct {
+			param  string
+			lookup map[string]int
+			fold   bool
+		}{
+			{"consumers", c.byConsumer, false},
+			{"roles", c.byRole, false},
+			{"headerValues", c.byHeaderValue, true},
+		} {
+			values, err := extractStringList(tierParams, selector.param)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tier %q: %w", name, err)
+			}
+			for _, value := range values {
+				if selector.fold {
+					value = strings.ToLower(value)
+				}
+				if other, exists := selector.lookup[value]; exists {
+					return nil, fmt.Errorf("'%s' value %q is in both tier %q and tier %q", selector.param, value, c.tiers[other].name, name)
+				}
+				selector.lookup[value] = i
+			}
+			selectors += len(values)
+		}
+		if selectors == 0 && c.tierMetadataKey == "" {
+			return nil, fmt.Errorf("tier %q must list at least one of 'consumers', 'roles' or 'headerValues', or 'tierMetadataKey' must be set", name)
+		}
+	}
+
+	if len(c.byHeaderValue) > 0 && c.header == "" {
+		return nil, fmt.Errorf("'tierHeader' is required when tiers list 'headerValues'")
+	}
+
+	return c, nil
+}
+
+// extractStringList extracts an optional list of non-empty strings
+func extractStringList(params map[string]interface{}, name string) ([]string, error) {
+	raw, ok := params[name]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%s' must be an array of strings", name)
+	}
+	values := make([]string, 0, len(list))
+	for _, itemRaw := range list {
+		item, ok := itemRaw.(string)
+		if !ok || item == "" {
+			return nil, fmt.Errorf("'%s' must contain non-empty strings", name)
+		}
+		values = append(values, item)
+	}
+	return values, nil
+}
+
+// selectTier returns the tier of the consumer, matched by authenticated username, then by
+// role, then by the tier name in metadata, then by header value. Identity from authentication
+// wins over the header, which is only trustworthy if set by the gateway, never by the client.
+func (c *tierConfig) selectTier(metadata map[string]interface{}, headers *policy.Headers) *tier {
+	if username, ok := metadata[MetadataKeyAuthUser].(string); ok {
+		if i, ok := c.byConsumer[username]; ok {
+			return &c.tiers[i]
+		}
+	}
+
+	if len(c.byRole) > 0 {
+		// Of several matching roles, the tier listed first wins
+		selected := -1
+		for _, role := range metadataStrings(metadata[c.rolesMetadataKey]) {
+			if i, ok := c.byRole[role]; ok && (selected < 0 || i < selected) {
+				selected = i
+			}
+		}
+		if selected >= 0 {
+			return &c.tiers[selected]
+		}
+	}
+
+	if c.tierMetadataKey != "" {
+		if name, ok := metadata[c.tierMetadataKey].(string); ok {
+			if i, ok := c.byName[name]; ok {
+				return &c.tiers[i]
+			}
+		}
+	}
+
+	if c.header != "" {
+		if values := headers.Get(c.header); len(values) > 0 {
+			if i, ok := c.byHeaderValue[strings.ToLower(strings.TrimSpace(values[0]))]; ok {
+				return &c.tiers[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// metadataStrings reads a metadata value given as a string list or a comma or space separated string
+func metadataStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	default:
+		return nil
+	}
+}
+
+// resolveTier returns the parameters with the range of the consumer's tier. Consumers
+// without a tier keep the top-level min and max, which form the default tier.
+func (params ContentLengthGuardrailPolicyParams) resolveTier(metadata map[string]interface{}, headers *policy.Headers) ContentLengthGuardrailPolicyParams {
+	if params.Tiers == nil {
+		return params
+	}
+	if t := params.Tiers.selectTier(metadata, headers); t != nil {
+		params.Min = t.min
+		params.Max = t.max
+	}
+	return params
+}
@@ -0,0 +1,151 @@
+package contentlengthguardrail
+
+import (
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+func TestSelectTier(t *testing.T) {
+	config, err := parseTierConfig(map[string]interface{}{
+		"tierHeader":      "X-Plan",
+		"tierMetadataKey": "auth.tier",
+		"tiers": []interface{}{
+			map[string]interface{}{
+				"name": "gold", "min": 0, "max": 1000,
+				"consumers": []interface{}{"alice"}, "roles": []interface{}{"admin"}, "headerValues": []interface{}{"Gold"},
+			},
+			map[string]interface{}{
+				"name": "silver", "min": 0, "max": 500,
+				"consumers": []interface{}{"bob"}, "roles": []interface{}{"staff"}, "headerValues": []interface{}{"silver"},
+			},
+			map[string]interface{}{"name": "bronze", "min": 0, "max": 100},
+		},
+	})
+	if err != nil {
+		t.Fatalf("parseTierConfig: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		headers  map[string][]string
+		want     string
+	}{
+		{
+			name:     "consumer",
+			metadata: map[string]interface{}{MetadataKeyAuthUser: "bob"},
+			want:     "silver",
+		},
+		{
+			name:     "consumer wins over role",
+			metadata: map[string]interface{}{MetadataKeyAuthUser: "bob", MetadataKeyAuthRoles: []string{"admin"}},
+			want:     "silver",
+		},
+		{
+			name:     "first listed tier wins among roles",
+			metadata: map[string]interface{}{MetadataKeyAuthRoles: "staff, admin"},
+			want:     "gold",
+		},
+		{
+			name:     "role wins over metadata tier name",
+			metadata: map[string]interface{}{MetadataKeyAuthRoles: []interface{}{"staff"}, "auth.tier": "bronze"},
+			want:     "silver",
+		},
+		{
+			name:     "metadata tier name wins over header",
+			metadata: map[string]interface{}{"auth.tier": "bronze"},
+			headers:  map[string][]string{"x-plan": {"gold"}},
+			want:     "bronze",
+		},
+		{
+			name:    "header value ignores case",
+			headers: map[string][]string{"x-plan": {" GOLD "}},
+			want:    "gold",
+		},
+		{
+			name:     "unknown consumer and header",
+			metadata: map[string]interface{}{MetadataKeyAuthUser: "carol", "auth.tier": "platinum"},
+			headers:  map[string][]string{"x-plan": {"platinum"}},
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.metadata == nil {
+				tt.metadata = map[string]interface{}{}
+			}
+			if tt.headers == nil {
+				tt.headers = map[string][]string{}
+			}
+			got := ""
+			if selected := config.selectTier(tt.metadata, policy.NewHeaders(tt.headers)); selected != nil {
+				got = selected.name
+			}
+			if got != tt.want {
+				t.Errorf("selectTier = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTierConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{name: "no tiers", params: map[string]interface{}{"tiers": []interface{}{}}},
+		{name: "duplicate name", params: map[string]interface{}{"tiers": []interface{}{
+			map[string]interface{}{"name": "gold", "min": 0, "max": 10, "consumers": []interface{}{"alice"}},
+			map[string]interface{}{"name": "gold", "min": 0, "max": 10, "consumers": []interface{}{"bob"}},
+		}}},
+		{name: "consumer in two tiers", params: map[string]interface{}{"tiers": []interface{}{
+			map[string]interface{}{"name": "gold", "min": 0, "max": 10, "consumers": []interface{}{"alice"}},
+			map[string]interface{}{"name": "silver", "min": 0, "max": 10, "consumers": []interface{}{"alice"}},
+		}}},
+		{name: "tier without selectors", params: map[string]interface{}{"tiers": []interface{}{
+			map[string]interface{}{"name": "gold", "min": 0, "max": 10},
+		}}},
+		{name: "header values without tier header", params: map[string]interface{}{"tiers": []interface{}{
+			map[string]interface{}{"name": "gold", "min": 0, "max": 10, "headerValues": []interface{}{"gold"}},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseTierConfig(tt.params); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestTierRange(t *testing.T) {
+	p := newTestPolicy(t, map[string]interface{}{
+		"request": map[string]interface{}{
+			"min": 0, "max": 2, "unit": "words",
+			"tiers": []interface{}{
+				map[string]interface{}{"name": "gold", "min": 0, "max": 5, "consumers": []interface{}{"alice"}},
+			},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		username string
+		status   int
+	}{
+		{name: "tier range", username: "alice", status: 0},
+		{name: "default tier", username: "bob", status: GuardrailErrorCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := map[string]interface{}{MetadataKeyAuthUser: tt.username}
+			if got := requestStatus(t, runRequest(p, nil, "one two three four", metadata)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package contentlengthguardrail
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tiktoken-go/tokenizer"
+)
+
+const (
+	// DefaultTokenizer is the BPE vocabulary used by GPT-4 and GPT-3.5 class models
+	DefaultTokenizer = string(tokenizer.Cl100kBase)
+)
+
+// supportedTokenizers are the embedded BPE vocabularies, keyed by encoding name
+var supportedTokenizers = map[string]tokenizer.Encoding{
+	string(tokenizer.Cl100kBase): tokenizer.Cl100kBase,
+	string(tokenizer.O200kBase):  tokenizer.O200kBase,
+	string(tokenizer.P50kBase):   tokenizer.P50kBase,
+	string(tokenizer.P50kEdit):   tokenizer.P50kEdit,
+	string(tokenizer.R50kBase):   tokenizer.R50kBase,
+}
+
+var (
+	tokenizersMu sync.Mutex
+	// tokenizers caches loaded codecs so policy instances share a vocabulary
+	tokenizers = make(map[string]tokenizer.Codec)
+)
+
+// loadTokenizer returns the codec for an encoding name, loading its vocabulary on first use
+func loadTokenizer(name string) (tokenizer.Codec, error) {
+	encoding, ok := supportedTokenizers[name]
+	if !ok {
+		names := make([]string, 0, len(supportedTokenizers))
+		for n := range supportedTokenizers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("'tokenizer' must be one of %s", strings.Join(names, ", "))
+	}
+
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+
+	if codec, ok := tokenizers[name]; ok {
+		return codec, nil
+	}
+	codec, err := tokenizer.Get(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load tokenizer %q: %w", name, err)
+	}
+	tokenizers[name] = codec
+	return codec, nil
+}
@@ -0,0 +1,115 @@
+package contentlengthguardrail
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+// largePrompt builds a prompt of roughly the given size in bytes from mixed prose, code and non-Latin text
+func largePrompt(size int) string {
+	const sample = "You are a helpful assistant. Summarize the following report in three bullet points.\n" +
+		"func main() { fmt.Println(\"hello, world\") }\n" +
+		"Le rapport trimestriel montre une croissance de 12% des revenus. 日本語のテキストも含まれています。\n"
+	return strings.Repeat(sample, size/len(sample)+1)[:size]
+}
+
+func benchmarkTokenCount(b *testing.B, encoding string, size int) {
+	codec, err := loadTokenizer(encoding)
+	if err != nil {
+		b.Fatal(err)
+	}
+	prompt := largePrompt(size)
+
+	b.SetBytes(int64(len(prompt)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Count(prompt); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTokenCountCl100k16KB(b *testing.B)  { benchmarkTokenCount(b, "cl100k_base", 16<<10) }
+func BenchmarkTokenCountCl100k256KB(b *testing.B) { benchmarkTokenCount(b, "cl100k_base", 256<<10) }
+func BenchmarkTokenCountO200k16KB(b *testing.B)   { benchmarkTokenCount(b, "o200k_base", 16<<10) }
+func BenchmarkTokenCountO200k256KB(b *testing.B)  { benchmarkTokenCount(b, "o200k_base", 256<<10) }
+
+func BenchmarkLoadTokenizer(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := loadTokenizer("o200k_base"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidatePayloadTokens(b *testing.B) {
+	p, err := GetPolicy(policy.PolicyMetadata{}, map[string]interface{}{
+		"request": map[string]interface{}{
+			"min":       0,
+			"max":       1000000,
+			"jsonPath":  "$.prompt",
+			"unit":      "tokens",
+			"tokenizer": "cl100k_base",
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	guardrail := p.(*ContentLengthGuardrailPolicy)
+	payload, err := json.Marshal(map[string]string{"prompt": largePrompt(64 << 10)})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := guardrail.validatePayload(payload, guardrail.requestParams, false, nil).(policy.UpstreamRequestModifications); !ok {
+			b.Fatal("expected the payload to pass validation")
+		}
+	}
+}
+
+func TestTokenRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokenizer string
+		max       int
+		prompt    string
+		status    int
+	}{
+		{name: "cl100k within range", tokenizer: "cl100k_base", max: 2, prompt: "hello world", status: 0},
+		{name: "cl100k above range", tokenizer: "cl100k_base", max: 1, prompt: "hello world", status: GuardrailErrorCode},
+		{name: "o200k within range", tokenizer: "o200k_base", max: 2, prompt: "hello world", status: 0},
+		{name: "default tokenizer", max: 1, prompt: "hello world", status: GuardrailErrorCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{"min": 0, "max": tt.max, "jsonPath": "$.prompt", "unit": "tokens"}
+			if tt.tokenizer != "" {
+				params["tokenizer"] = tt.tokenizer
+			}
+			p := newTestPolicy(t, map[string]interface{}{"request": params})
+			body, err := json.Marshal(map[string]string{"prompt": tt.prompt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := requestStatus(t, runRequest(p, nil, string(body), nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestUnknownTokenizer(t *testing.T) {
+	_, err := GetPolicy(policy.PolicyMetadata{}, map[string]interface{}{
+		"request": map[string]interface{}{"min": 0, "max": 10, "unit": "tokens", "tokenizer": "gpt-9000"},
+	})
+	if err == nil {
+		t.Error("expected an error for an unknown tokenizer")
+	}
+}
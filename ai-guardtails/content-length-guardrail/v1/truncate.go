@@ -0,0 +1,285 @@
+package contentlengthguardrail
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	utils "github.com/wso2/api-platform/sdk/utils"
+)
+
+const (
+	// What to do with content violating the range
+	OnViolationReject   = "reject"
+	OnViolationTruncate = "truncate"
+
+	// Metadata keys recording truncation of request and response content
+	MetadataKeyRequestTruncated       = "contentlengthguardrail:request_truncated"
+	MetadataKeyRequestOriginalLength  = "contentlengthguardrail:request_original_length"
+	MetadataKeyRequestTruncatedPaths  = "contentlengthguardrail:request_truncated_paths"
+	MetadataKeyResponseTruncated      = "contentlengthguardrail:response_truncated"
+	MetadataKeyResponseOriginalLength = "contentlengthguardrail:response_original_length"
+	MetadataKeyResponseTruncatedPaths = "contentlengthguardrail:response_truncated_paths"
+)
+
+// truncation is the outcome of truncating a payload
+type truncation struct {
+	payload []byte
+	// originalLength is the length of the longest truncated value
+	originalLength int
+	// paths are the JSONPaths of the truncated values, empty if the whole payload was truncated
+	paths []string
+}
+
+// truncatePayload cuts every value at the JSONPath that is longer than max down to max units
+// and writes it back into the payload. Lengths are measured as in validation, after
+// normalization if configured.
+func truncatePayload(payload []byte, params ContentLengthGuardrailPolicyParams) (*truncation, error) {
+	if params.JsonPath == "" {
+		value := cleanValue(string(payload))
+		count, _, err := measureText(value, params)
+		if err != nil {
+			return nil, err
+		}
+		truncated, err := truncateContent(value, params)
+		if err != nil {
+			return nil, err
+		}
+		return &truncation{payload: []byte(truncated), originalLength: count}, nil
+	}
+
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal(payload, &jsonData); err != nil {
+		return nil, err
+	}
+
+	var targets []jsonPathMatch
+	if isWildcardJSONPath(params.JsonPath) {
+		matches, err := extractJSONPathMatches(payload, params.JsonPath)
+		if err != nil {
+			return nil, err
+		}
+		targets = matches
+	} else {
+		value, err := utils.ExtractStringValueFromJsonpath(payload, params.JsonPath)
+		if err != nil {
+			return nil, err
+		}
+		targets = []jsonPathMatch{{path: params.JsonPath, value: value}}
+	}
+
+	result := &truncation{}
+	for _, target := range targets {
+		value := cleanValue(target.value)
+		count, _, err := measureText(value, params)
+		if err != nil {
+			return nil, err
+		}
+		if count <= params.Max {
+			continue
+		}
+		truncated, err := truncateContent(value, params)
+		if err != nil {
+			return nil, err
+		}
+		// Set the truncated value at the JSONPath location, as PII masking does
+		if err := utils.SetValueAtJSONPath(jsonData, target.path, truncated); err != nil {
+			return nil, fmt.Errorf("cannot write truncated value to %s: %w", target.path, err)
+		}
+		result.paths = append(result.paths, target.path)
+		if count > result.originalLength {
+			result.originalLength = count
+		}
+	}
+
+	updatedPayload, err := json.Marshal(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	result.payload = updatedPayload
+	return result, nil
+}
+
+// truncateContent cuts text to at most max units, reserving room for the truncation marker.
+// Cuts fall on grapheme cluster boundaries, so the result is valid UTF-8 and never splits
+// a user-perceived character. If the marker alone does not fit, it is left out.
+func truncateContent(text string, params ContentLengthGuardrailPolicyParams) (string, error) {
+	limit := params.Max
+	marker := params.TruncationMarker
+	if marker != "" {
+		markerLength, _, err := measureText(marker, params)
+		if err != nil {
+			return "", err
+		}
+		if markerLength < limit {
+			limit -= markerLength
+		} else {
+			marker = ""
+		}
+	}
+
+	var truncated string
+	var err error
+	if len(params.Normalize) > 0 {
+		// The raw text is forwarded, so it is cut where its normalized length reaches the limit
+		truncated, err = prefixByNormalizedLength(text, limit, params)
+	} else {
+		truncated, err = prefixByUnit(text, limit, params)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(truncated) == len(text) {
+		return text, nil
+	}
+	return strings.TrimRightFunc(truncated, unicode.IsSpace) + marker, nil
+}
+
+// prefixByUnit returns the longest prefix of text that is at most limit units long
+func prefixByUnit(text string, limit int, params ContentLengthGuardrailPolicyParams) (string, error) {
+	switch params.Unit {
+	case UnitCharacters:
+		if params.CharacterMode == CharacterModeGraphemes {
+			return prefixByGraphemes(text, limit, func(string) int { return 1 }), nil
+		}
+		return prefixByGraphemes(text, limit, utf8.RuneCountInString), nil
+	case UnitWords:
+		return prefixBySegments(text, limit, uniseg.FirstWordInString, isCountedWord), nil
+	case UnitSentences:
+		return prefixBySegments(text, limit, uniseg.FirstSentenceInString, func(sentence string) bool {
+			return strings.TrimSpace(sentence) != ""
+		}), nil
+	case UnitLines:
+		return prefixByLines(text, limit), nil
+	case UnitTokens:
+		_, tokens, err := params.tokenizer.Encode(text)
+		if err != nil {
+			return "", err
+		}
+		if len(tokens) <= limit {
+			return text, nil
+		}
+		// Tokens may split multi-byte characters, so cut at the last grapheme boundary within them
+		prefixLength := 0
+		for _, token := range tokens[:limit] {
+			prefixLength += len(token)
+		}
+		return prefixByGraphemes(text, prefixLength, func(cluster string) int { return len(cluster) }), nil
+	default:
+		return prefixByGraphemes(text, limit, func(cluster string) int { return len(cluster) }), nil
+	}
+}
+
+// prefixByNormalizedLength returns the longest prefix of whole grapheme clusters whose length
+// after normalization is at most limit units. The normalized length grows with the prefix, so
+// the cut is found by binary search over the cluster boundaries.
+func prefixByNormalizedLength(text string, limit int, params ContentLengthGuardrailPolicyParams) (string, error) {
+	var ends []int
+	end := 0
+	state := -1
+	rest := text
+	var cluster string
+	for len(rest) > 0 {
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		end += len(cluster)
+		ends = append(ends, end)
+	}
+
+	var measureErr error
+	// The first prefix of clusters that is too long; the one before it fits
+	n := sort.Search(len(ends), func(i int) bool {
+		count, _, err := measureText(text[:ends[i]], params)
+		if err != nil {
+			measureErr = err
+			return true
+		}
+		return count > limit
+	})
+	if measureErr != nil {
+		return "", measureErr
+	}
+	if n == 0 {
+		return "", nil
+	}
+	return text[:ends[n-1]], nil
+}
+
+// prefixByGraphemes returns the longest prefix of whole grapheme clusters whose total size is at most limit
+func prefixByGraphemes(text string, limit int, size func(cluster string) int) string {
+	total := 0
+	end := 0
+	state := -1
+	rest := text
+	var cluster string
+	for len(rest) > 0 {
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		total += size(cluster)
+		if total > limit {
+			break
+		}
+		end += len(cluster)
+	}
+	return text[:end]
+}
+
+// prefixBySegments returns the prefix containing at most limit counted segments
+func prefixBySegments(text string, limit int, next func(string, int) (string, string, int), counted func(string) bool) string {
+	count := 0
+	end := 0
+	state := -1
+	rest := text
+	var segment string
+	for len(rest) > 0 {
+		segment, rest, state = next(rest, state)
+		if counted(segment) {
+			count++
+			if count > limit {
+				break
+			}
+		}
+		end += len(segment)
+	}
+	return text[:end]
+}
+
+// prefixByLines returns the first limit lines, without the line break ending the last one
+func prefixByLines(text string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	lines := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\n' && text[i] != '\r' {
+			continue
+		}
+		lines++
+		if lines == limit {
+			return text[:i]
+		}
+		if text[i] == '\r' && i+1 < len(text) && text[i+1] == '\n' {
+			i++
+		}
+	}
+	return text
+}
+
+// recordTruncation records the truncation in metadata for later policies and analytics
+func recordTruncation(metadata map[string]interface{}, result *truncation, isResponse bool) {
+	if metadata == nil {
+		return
+	}
+	truncatedKey, originalLengthKey, pathsKey := MetadataKeyRequestTruncated, MetadataKeyRequestOriginalLength, MetadataKeyRequestTruncatedPaths
+	if isResponse {
+		truncatedKey, originalLengthKey, pathsKey = MetadataKeyResponseTruncated, MetadataKeyResponseOriginalLength, MetadataKeyResponseTruncatedPaths
+	}
+	metadata[truncatedKey] = true
+	metadata[originalLengthKey] = result.originalLength
+	if len(result.paths) > 0 {
+		metadata[pathsKey] = result.paths
+	}
+}
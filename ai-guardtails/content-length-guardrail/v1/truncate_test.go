@@ -0,0 +1,149 @@
+package contentlengthguardrail
+
+import (
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+func TestTruncateContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		params ContentLengthGuardrailPolicyParams
+		want   string
+	}{
+		{
+			name:   "bytes",
+			text:   "hello world",
+			params: ContentLengthGuardrailPolicyParams{Max: 5, Unit: UnitBytes},
+			want:   "hello",
+		},
+		{
+			name:   "bytes never split a character",
+			text:   "héllo",
+			params: ContentLengthGuardrailPolicyParams{Max: 2, Unit: UnitBytes},
+			want:   "h",
+		},
+		{
+			name:   "code points keep combining marks with their base",
+			text:   "abce\u0301",
+			params: ContentLengthGuardrailPolicyParams{Max: 4, Unit: UnitCharacters},
+			want:   "abc",
+		},
+		{
+			name:   "graphemes",
+			text:   "e\u0301e\u0301",
+			params: ContentLengthGuardrailPolicyParams{Max: 1, Unit: UnitCharacters, CharacterMode: CharacterModeGraphemes},
+			want:   "e\u0301",
+		},
+		{
+			name:   "words",
+			text:   "one two three four",
+			params: ContentLengthGuardrailPolicyParams{Max: 2, Unit: UnitWords},
+			want:   "one two",
+		},
+		{
+			name:   "lines",
+			text:   "a\nb\r\nc",
+			params: ContentLengthGuardrailPolicyParams{Max: 2, Unit: UnitLines},
+			want:   "a\nb",
+		},
+		{
+			name:   "marker counts towards max",
+			text:   "hello world",
+			params: ContentLengthGuardrailPolicyParams{Max: 5, Unit: UnitCharacters, TruncationMarker: "…"},
+			want:   "hell…",
+		},
+		{
+			name:   "marker left out if it does not fit",
+			text:   "hello world",
+			params: ContentLengthGuardrailPolicyParams{Max: 2, Unit: UnitCharacters, TruncationMarker: "[cut]"},
+			want:   "he",
+		},
+		{
+			name:   "normalized length decides the raw cut",
+			text:   "a    b    c    d",
+			params: ContentLengthGuardrailPolicyParams{Max: 3, Unit: UnitCharacters, Normalize: []string{NormalizeCollapseWhitespace}},
+			want:   "a    b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := truncateContent(tt.text, tt.params)
+			if err != nil {
+				t.Fatalf("truncateContent: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("truncateContent(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		body     string
+		want     string
+		original int
+		paths    []string
+	}{
+		{
+			name:     "value at jsonPath",
+			params:   map[string]interface{}{"min": 0, "max": 5, "unit": "characters", "jsonPath": "$.prompt"},
+			body:     `{"prompt": "hello world", "model": "m"}`,
+			want:     `{"model":"m","prompt":"hello"}`,
+			original: 11,
+		},
+		{
+			name: "each wildcard match",
+			params: map[string]interface{}{
+				"min": 0, "max": 3, "unit": "characters",
+				"jsonPath": "$.messages[*].content", "aggregate": "each",
+			},
+			body:     `{"messages": [{"content": "abc"}, {"content": "abcdef"}]}`,
+			want:     `{"messages":[{"content":"abc"},{"content":"abc"}]}`,
+			original: 6,
+			paths:    []string{"$.messages[1].content"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params["onViolation"] = OnViolationTruncate
+			p := newTestPolicy(t, map[string]interface{}{"request": tt.params})
+			metadata := map[string]interface{}{}
+			mods, ok := runRequest(p, nil, tt.body, metadata).(policy.UpstreamRequestModifications)
+			if !ok {
+				t.Fatal("expected the request to be passed on")
+			}
+			if string(mods.Body) != tt.want {
+				t.Errorf("body = %s, want %s", mods.Body, tt.want)
+			}
+			if metadata[MetadataKeyRequestTruncated] != true {
+				t.Error("truncation was not recorded")
+			}
+			if got := metadata[MetadataKeyRequestOriginalLength]; got != tt.original {
+				t.Errorf("original length = %v, want %d", got, tt.original)
+			}
+			if tt.paths != nil {
+				paths, _ := metadata[MetadataKeyRequestTruncatedPaths].([]string)
+				if len(paths) != len(tt.paths) || paths[0] != tt.paths[0] {
+					t.Errorf("truncated paths = %v, want %v", paths, tt.paths)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateStillRejectsShortContent(t *testing.T) {
+	p := newTestPolicy(t, map[string]interface{}{
+		"request": map[string]interface{}{"min": 3, "max": 5, "unit": "characters", "onViolation": "truncate"},
+	})
+	if got := requestStatus(t, runRequest(p, nil, "hi", nil)); got != GuardrailErrorCode {
+		t.Errorf("status = %d, want %d", got, GuardrailErrorCode)
+	}
+}
@@ -0,0 +1,99 @@
+package contentlengthguardrail
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+const (
+	// Units content length can be measured in
+	UnitBytes      = "bytes"
+	UnitCharacters = "characters"
+	UnitWords      = "words"
+	UnitSentences  = "sentences"
+	UnitLines      = "lines"
+	UnitTokens     = "tokens"
+
+	// How characters are counted
+	CharacterModeCodePoints = "codePoints"
+	CharacterModeGraphemes  = "graphemes"
+)
+
+var validUnits = map[string]bool{
+	UnitBytes:      true,
+	UnitCharacters: true,
+	UnitWords:      true,
+	UnitSentences:  true,
+	UnitLines:      true,
+	UnitTokens:     true,
+}
+
+// countContent measures text in the configured unit
+func countContent(text string, params ContentLengthGuardrailPolicyParams) (int, error) {
+	switch params.Unit {
+	case UnitCharacters:
+		if params.CharacterMode == CharacterModeGraphemes {
+			// User-perceived characters, e.g. a Sinhala consonant with its vowel sign is one
+			return uniseg.GraphemeClusterCount(text), nil
+		}
+		return utf8.RuneCountInString(text), nil
+	case UnitWords:
+		return countWords(text), nil
+	case UnitSentences:
+		return countSentences(text), nil
+	case UnitLines:
+		return countLines(text), nil
+	case UnitTokens:
+		return params.tokenizer.Count(text)
+	default:
+		return len(text), nil
+	}
+}
+
+// countWords counts Unicode (UAX #29) word segments containing a letter or number.
+// Scripts written without spaces, such as CJK, count each ideograph as a word.
+func countWords(text string) int {
+	count := 0
+	state := -1
+	var word string
+	for len(text) > 0 {
+		word, text, state = uniseg.FirstWordInString(text, state)
+		if isCountedWord(word) {
+			count++
+		}
+	}
+	return count
+}
+
+// isCountedWord reports whether a word segment contains a letter or number, i.e. is not
+// whitespace or punctuation
+func isCountedWord(word string) bool {
+	return strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0
+}
+
+// countSentences counts Unicode (UAX #29) sentence segments that are not blank
+func countSentences(text string) int {
+	count := 0
+	state := -1
+	var sentence string
+	for len(text) > 0 {
+		sentence, text, state = uniseg.FirstSentenceInString(text, state)
+		if strings.TrimSpace(sentence) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// countLines counts lines separated by \n, \r\n or \r. A trailing line break does not start a new line.
+func countLines(text string) int {
+	if text == "" {
+		return 0
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+}
@@ -0,0 +1,155 @@
+package contentlengthguardrail
+
+import (
+	"strconv"
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+// newTestPolicy builds a policy from its parameters, failing the test if they are invalid
+func newTestPolicy(t *testing.T, params map[string]interface{}) *ContentLengthGuardrailPolicy {
+	t.Helper()
+	p, err := GetPolicy(policy.PolicyMetadata{}, params)
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	return p.(*ContentLengthGuardrailPolicy)
+}
+
+// runRequest passes a buffered request with a matching Content-Length through the policy
+func runRequest(p *ContentLengthGuardrailPolicy, headers map[string][]string, body string, metadata map[string]interface{}) policy.RequestAction {
+	all := map[string][]string{"content-length": {strconv.Itoa(len(body))}}
+	for name, values := range headers {
+		all[name] = values
+	}
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	return p.OnRequest(&policy.RequestContext{
+		SharedContext: &policy.SharedContext{Metadata: metadata},
+		Headers:       policy.NewHeaders(all),
+		Body:          &policy.Body{Content: []byte(body), EndOfStream: true, Present: true},
+	}, nil)
+}
+
+// runResponse passes a buffered response through the policy
+func runResponse(p *ContentLengthGuardrailPolicy, headers map[string][]string, body string, metadata map[string]interface{}) policy.ResponseAction {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	return p.OnResponse(&policy.ResponseContext{
+		SharedContext:   &policy.SharedContext{Metadata: metadata},
+		RequestHeaders:  policy.NewHeaders(map[string][]string{}),
+		ResponseHeaders: policy.NewHeaders(headers),
+		ResponseBody:    &policy.Body{Content: []byte(body), EndOfStream: true, Present: true},
+		ResponseStatus:  200,
+	}, nil)
+}
+
+// requestStatus returns the status of a rejected request, or 0 if it was passed on
+func requestStatus(t *testing.T, action policy.RequestAction) int {
+	t.Helper()
+	switch a := action.(type) {
+	case policy.ImmediateResponse:
+		return a.StatusCode
+	case policy.UpstreamRequestModifications:
+		return 0
+	default:
+		t.Fatalf("unexpected request action %T", action)
+		return -1
+	}
+}
+
+// responseStatus returns the status a response was replaced with, or 0 if it was passed on
+func responseStatus(t *testing.T, action policy.ResponseAction) int {
+	t.Helper()
+	mods, ok := action.(policy.UpstreamResponseModifications)
+	if !ok {
+		t.Fatalf("unexpected response action %T", action)
+	}
+	if mods.StatusCode == nil {
+		return 0
+	}
+	return *mods.StatusCode
+}
+
+func TestCountContent(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		unit          string
+		characterMode string
+		want          int
+	}{
+		{name: "bytes", text: "héllo", unit: UnitBytes, want: 6},
+		{name: "code points", text: "héllo", unit: UnitCharacters, want: 5},
+		{name: "combining mark as code points", text: "e\u0301", unit: UnitCharacters, want: 2},
+		{name: "combining mark as grapheme", text: "e\u0301", unit: UnitCharacters, characterMode: CharacterModeGraphemes, want: 1},
+		{name: "sinhala vowel sign as grapheme", text: "කා", unit: UnitCharacters, characterMode: CharacterModeGraphemes, want: 1},
+		{name: "emoji sequence as grapheme", text: "👍🏽", unit: UnitCharacters, characterMode: CharacterModeGraphemes, want: 1},
+		{name: "words skip punctuation", text: "Hello, world! It's 2024.", unit: UnitWords, want: 4},
+		{name: "words in ideographs", text: "你好", unit: UnitWords, want: 2},
+		{name: "sentences", text: "One. Two? Three!", unit: UnitSentences, want: 3},
+		{name: "blank sentences", text: "   ", unit: UnitSentences, want: 0},
+		{name: "lines with mixed breaks", text: "a\nb\r\nc\rd", unit: UnitLines, want: 4},
+		{name: "trailing line break", text: "a\nb\n", unit: UnitLines, want: 2},
+		{name: "empty lines", text: "", unit: UnitLines, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := ContentLengthGuardrailPolicyParams{Unit: tt.unit, CharacterMode: tt.characterMode}
+			got, err := countContent(tt.text, params)
+			if err != nil {
+				t.Fatalf("countContent: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("countContent(%q, %s) = %d, want %d", tt.text, tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		body   string
+		status int
+	}{
+		{
+			name:   "words within range",
+			params: map[string]interface{}{"min": 1, "max": 3, "unit": "words"},
+			body:   "one two three",
+			status: 0,
+		},
+		{
+			name:   "words above range",
+			params: map[string]interface{}{"min": 1, "max": 3, "unit": "words"},
+			body:   "one two three four",
+			status: GuardrailErrorCode,
+		},
+		{
+			name:   "characters at jsonPath",
+			params: map[string]interface{}{"min": 1, "max": 5, "unit": "characters", "jsonPath": "$.prompt"},
+			body:   `{"prompt": "héllo", "padding": "not measured"}`,
+			status: 0,
+		},
+		{
+			name:   "inverted range",
+			params: map[string]interface{}{"min": 1, "max": 5, "unit": "characters", "invert": true},
+			body:   "short",
+			status: GuardrailErrorCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPolicy(t, map[string]interface{}{"request": tt.params})
+			if got := requestStatus(t, runRequest(p, nil, tt.body, nil)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package jsonschemaguardrail
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	ContentEncodingHeader = "content-encoding"
+
+	DefaultMaxDecompressedSize   = 32 << 20
+	DefaultMaxDecompressionRatio = 100
+)
+
+// decompressionLimits caps decoded bodies, so a small compressed body cannot expand into a
+// decompression bomb
+type decompressionLimits struct {
+	maxSize  int64
+	maxRatio int64
+}
+
+// parseDecompressionLimits parses and validates the decompression parameters
+func parseDecompressionLimits(params map[string]interface{}) (decompressionLimits, error) {
+	limits := decompressionLimits{maxSize: DefaultMaxDecompressedSize, maxRatio: DefaultMaxDecompressionRatio}
+
+	if maxSizeRaw, ok := params["maxSize"]; ok {
+		maxSize, err := extractInt(maxSizeRaw)
+		if err != nil || maxSize <= 0 {
+			return limits, fmt.Errorf("'maxSize' must be an integer greater than 0")
+		}
+		limits.maxSize = int64(maxSize)
+	}
+
+	if maxRatioRaw, ok := params["maxRatio"]; ok {
+		maxRatio, err := extractInt(maxRatioRaw)
+		if err != nil || maxRatio <= 0 {
+			return limits, fmt.Errorf("'maxRatio' must be an integer greater than 0")
+		}
+		limits.maxRatio = int64(maxRatio)
+	}
+
+	return limits, nil
+}
+
+// contentEncodings returns the content codings applied to a body, in the order they were applied
+func contentEncodings(headers *policy.Headers) []string {
+	var codings []string
+	for _, value := range headers.Get(ContentEncodingHeader) {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "" && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+// decompressBody decodes a body with the given content codings. It fails if the decoded body
+// exceeds the size cap or the ratio cap relative to the encoded body.
+func decompressBody(body []byte, codings []string, limits decompressionLimits) ([]byte, error) {
+	if len(body) == 0 || len(codings) == 0 {
+		return body, nil
+	}
+
+	limit := limits.maxSize
+	if ratioLimit := int64(len(body)) * limits.maxRatio; ratioLimit < limit {
+		limit = ratioLimit
+	}
+
+	// Codings are removed in the reverse order they were applied
+	for i := len(codings) - 1; i >= 0; i-- {
+		reader, err := newDecoder(codings[i], body)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := io.ReadAll(io.LimitReader(reader, limit+1))
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s body: %w", codings[i], err)
+		}
+		if int64(len(decoded)) > limit {
+			return nil, fmt.Errorf("decompressed body exceeds the limit of %d bytes", limit)
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+// newDecoder returns a reader decoding a body of the content coding
+func newDecoder(coding string, body []byte) (io.ReadCloser, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// HTTP deflate is zlib-wrapped, but some servers send raw deflate
+		if reader, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			return reader, nil
+		}
+		return flate.NewReader(bytes.NewReader(body)), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(bytes.NewReader(body))), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", coding)
+	}
+}
+
+// withoutContentEncoding drops the Content-Encoding of an encoded response whose body the
+// action replaces, since rewritten bodies and errors are sent decoded
+func withoutContentEncoding(action policy.ResponseAction, codings []string) policy.ResponseAction {
+	if mods, ok := action.(policy.UpstreamResponseModifications); ok && mods.Body != nil && len(codings) > 0 {
+		mods.RemoveHeaders = append(mods.RemoveHeaders, ContentEncodingHeader)
+		return mods
+	}
+	return action
+}
+
+// withoutRequestContentEncoding drops the Content-Encoding of an encoded request whose body
+// the action replaces, since rewritten bodies are sent decoded
+func withoutRequestContentEncoding(action policy.RequestAction, codings []string) policy.RequestAction {
+	if mods, ok := action.(policy.UpstreamRequestModifications); ok && mods.Body != nil && len(codings) > 0 {
+		mods.RemoveHeaders = append(mods.RemoveHeaders, ContentEncodingHeader)
+		return mods
+	}
+	return action
+}
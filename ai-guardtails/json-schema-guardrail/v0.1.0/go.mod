@@ -9,4 +9,8 @@ require (
 	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415 // indirect
 )
 
-require github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.17.11
+	github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
+)
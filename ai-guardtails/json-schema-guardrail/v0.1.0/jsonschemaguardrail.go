@@ -3,6 +3,7 @@ package jsonschemaguardrail
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
 	utils "github.com/wso2/api-platform/sdk/utils"
@@ -19,6 +20,9 @@ type JSONSchemaGuardrailPolicy struct {
 	hasResponseParams bool
 	requestParams     JSONSchemaGuardrailPolicyParams
 	responseParams    JSONSchemaGuardrailPolicyParams
+
+	// decompression caps bodies decoded from their Content-Encoding
+	decompression decompressionLimits
 }
 
 type JSONSchemaGuardrailPolicyParams struct {
@@ -32,7 +36,9 @@ func GetPolicy(
 	metadata policy.PolicyMetadata,
 	params map[string]interface{},
 ) (policy.Policy, error) {
-	p := &JSONSchemaGuardrailPolicy{}
+	p := &JSONSchemaGuardrailPolicy{
+		decompression: decompressionLimits{maxSize: DefaultMaxDecompressedSize, maxRatio: DefaultMaxDecompressionRatio},
+	}
 
 	// Extract and parse request parameters if present
 	if requestParamsRaw, ok := params["request"].(map[string]interface{}); ok {
@@ -59,6 +65,15 @@ func GetPolicy(
 		return nil, fmt.Errorf("at least one of 'request' or 'response' parameters must be provided")
 	}
 
+	// Extract optional decompression parameters
+	if decompressionRaw, ok := params["decompression"].(map[string]interface{}); ok {
+		limits, err := parseDecompressionLimits(decompressionRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decompression parameters: %w", err)
+		}
+		p.decompression = limits
+	}
+
 	return p, nil
 }
 
@@ -116,12 +131,47 @@ func parseParams(params map[string]interface{}) (JSONSchemaGuardrailPolicyParams
 	return result, nil
 }
 
+// extractInt safely extracts an integer from various types
+func extractInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("expected an integer but got %v", v)
+		}
+		return int(v), nil
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, err
+		}
+		if parsed != float64(int(parsed)) {
+			return 0, fmt.Errorf("expected an integer but got %v", v)
+		}
+		return int(parsed), nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to int", value)
+	}
+}
+
 // Mode returns the processing mode for this policy
 func (p *JSONSchemaGuardrailPolicy) Mode() policy.ProcessingMode {
+	// Need Content-Encoding to decode compressed bodies
+	requestHeaderMode := policy.HeaderModeSkip
+	if p.hasRequestParams {
+		requestHeaderMode = policy.HeaderModeProcess
+	}
+	responseHeaderMode := policy.HeaderModeSkip
+	if p.hasResponseParams {
+		responseHeaderMode = policy.HeaderModeProcess
+	}
 	return policy.ProcessingMode{
-		RequestHeaderMode:  policy.HeaderModeSkip,
+		RequestHeaderMode:  requestHeaderMode,
 		RequestBodyMode:    policy.BodyModeBuffer,
-		ResponseHeaderMode: policy.HeaderModeSkip,
+		ResponseHeaderMode: responseHeaderMode,
 		ResponseBodyMode:   policy.BodyModeBuffer,
 	}
 }
@@ -136,6 +186,10 @@ func (p *JSONSchemaGuardrailPolicy) OnRequest(ctx *policy.RequestContext, params
 	if ctx.Body != nil {
 		content = ctx.Body.Content
 	}
+	content, err := decompressBody(content, contentEncodings(ctx.Headers), p.decompression)
+	if err != nil {
+		return p.buildErrorResponse("Error decompressing request body", err, false, p.requestParams.ShowAssessment, nil).(policy.RequestAction)
+	}
 	return p.validatePayload(content, p.requestParams, false).(policy.RequestAction)
 }
 
@@ -149,7 +203,13 @@ func (p *JSONSchemaGuardrailPolicy) OnResponse(ctx *policy.ResponseContext, para
 	if ctx.ResponseBody != nil {
 		content = ctx.ResponseBody.Content
 	}
-	return p.validatePayload(content, p.responseParams, true).(policy.ResponseAction)
+	codings := contentEncodings(ctx.ResponseHeaders)
+	content, err := decompressBody(content, codings, p.decompression)
+	if err != nil {
+		action := p.buildErrorResponse("Error decompressing response body", err, true, p.responseParams.ShowAssessment, nil).(policy.ResponseAction)
+		return withoutContentEncoding(action, codings)
+	}
+	return withoutContentEncoding(p.validatePayload(content, p.responseParams, true).(policy.ResponseAction), codings)
 }
 
 // validatePayload validates payload against JSON schema
@@ -2,4 +2,8 @@ module github.com/renuka-fernando/api-platform-gateway-extensions/pii-masking-re
 
 go 1.23.0
 
-require github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.17.11
+	github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
+)
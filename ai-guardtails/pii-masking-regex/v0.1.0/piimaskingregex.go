@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
@@ -23,6 +24,9 @@ var textCleanRegexCompiled = regexp.MustCompile(TextCleanRegex)
 // PIIMaskingRegexPolicy implements regex-based PII masking
 type PIIMaskingRegexPolicy struct {
 	params PIIMaskingRegexPolicyParams
+
+	// decompression caps bodies decoded from their Content-Encoding
+	decompression decompressionLimits
 }
 
 type PIIMaskingRegexPolicyParams struct {
@@ -35,7 +39,9 @@ func GetPolicy(
 	metadata policy.PolicyMetadata,
 	params map[string]interface{},
 ) (policy.Policy, error) {
-	p := &PIIMaskingRegexPolicy{}
+	p := &PIIMaskingRegexPolicy{
+		decompression: decompressionLimits{maxSize: DefaultMaxDecompressedSize, maxRatio: DefaultMaxDecompressionRatio},
+	}
 
 	// Parse parameters (piiEntities is required)
 	policyParams, err := parseParams(params, true) // true = piiEntities is required
@@ -44,6 +50,15 @@ func GetPolicy(
 	}
 	p.params = policyParams
 
+	// Extract optional decompression parameters
+	if decompressionRaw, ok := params["decompression"].(map[string]interface{}); ok {
+		limits, err := parseDecompressionLimits(decompressionRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decompression parameters: %w", err)
+		}
+		p.decompression = limits
+	}
+
 	return p, nil
 }
 
@@ -134,12 +149,38 @@ func parseParams(params map[string]interface{}, requirePIIEntities bool) (PIIMas
 	return result, nil
 }
 
+// extractInt safely extracts an integer from various types
+func extractInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("expected an integer but got %v", v)
+		}
+		return int(v), nil
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, err
+		}
+		if parsed != float64(int(parsed)) {
+			return 0, fmt.Errorf("expected an integer but got %v", v)
+		}
+		return int(parsed), nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to int", value)
+	}
+}
+
 // Mode returns the processing mode for this policy
 func (p *PIIMaskingRegexPolicy) Mode() policy.ProcessingMode {
 	return policy.ProcessingMode{
-		RequestHeaderMode:  policy.HeaderModeSkip,
+		RequestHeaderMode:  policy.HeaderModeProcess, // Need Content-Encoding to decode compressed bodies
 		RequestBodyMode:    policy.BodyModeBuffer,
-		ResponseHeaderMode: policy.HeaderModeSkip,
+		ResponseHeaderMode: policy.HeaderModeProcess,
 		ResponseBodyMode:   policy.BodyModeBuffer,
 	}
 }
@@ -154,12 +195,16 @@ func (p *PIIMaskingRegexPolicy) OnRequest(ctx *policy.RequestContext, params map
 	if ctx.Body == nil || ctx.Body.Content == nil {
 		return policy.UpstreamRequestModifications{}
 	}
-	payload := ctx.Body.Content
+	codings := contentEncodings(ctx.Headers)
+	payload, err := decompressBody(ctx.Body.Content, codings, p.decompression)
+	if err != nil {
+		return p.buildErrorResponse(fmt.Sprintf("error decompressing request body: %v", err), false).(policy.RequestAction)
+	}
 
 	// Extract value using JSONPath
 	extractedValue, err := utils.ExtractStringValueFromJsonpath(payload, p.params.JsonPath)
 	if err != nil {
-		return p.buildErrorResponse(fmt.Sprintf("error extracting value from JSONPath: %v", err)).(policy.RequestAction)
+		return p.buildErrorResponse(fmt.Sprintf("error extracting value from JSONPath: %v", err), false).(policy.RequestAction)
 	}
 
 	// Clean and trim
@@ -174,16 +219,14 @@ func (p *PIIMaskingRegexPolicy) OnRequest(ctx *policy.RequestContext, params map
 		// Masking mode: replace with placeholders and store mappings
 		modifiedContent, err = p.maskPIIFromContent(extractedValue, p.params.PIIEntities, ctx.Metadata)
 		if err != nil {
-			return p.buildErrorResponse(fmt.Sprintf("error masking PII: %v", err)).(policy.RequestAction)
+			return p.buildErrorResponse(fmt.Sprintf("error masking PII: %v", err), false).(policy.RequestAction)
 		}
 	}
 
 	// If content was modified, update the payload
 	if modifiedContent != "" && modifiedContent != extractedValue {
 		modifiedPayload := p.updatePayloadWithMaskedContent(payload, extractedValue, modifiedContent, p.params.JsonPath)
-		return policy.UpstreamRequestModifications{
-			Body: modifiedPayload,
-		}
+		return withoutRequestContentEncoding(policy.UpstreamRequestModifications{Body: modifiedPayload}, codings)
 	}
 
 	return policy.UpstreamRequestModifications{}
@@ -210,14 +253,19 @@ func (p *PIIMaskingRegexPolicy) OnResponse(ctx *policy.ResponseContext, params m
 	if ctx.ResponseBody == nil || ctx.ResponseBody.Content == nil {
 		return policy.UpstreamResponseModifications{}
 	}
-	payload := ctx.ResponseBody.Content
+	codings := contentEncodings(ctx.ResponseHeaders)
+	payload, err := decompressBody(ctx.ResponseBody.Content, codings, p.decompression)
+	if err != nil {
+		// Without the decoded body placeholders cannot be restored, so the response is replaced
+		// with an error rather than leaking them to the client
+		action := p.buildErrorResponse(fmt.Sprintf("error decompressing response body: %v", err), true).(policy.ResponseAction)
+		return withoutContentEncoding(action, codings)
+	}
 
 	// Restore PII in response
 	restoredContent := p.restorePIIInResponse(string(payload), maskedPIIMap)
 	if restoredContent != string(payload) {
-		return policy.UpstreamResponseModifications{
-			Body: []byte(restoredContent),
-		}
+		return withoutContentEncoding(policy.UpstreamResponseModifications{Body: []byte(restoredContent)}, codings)
 	}
 
 	return policy.UpstreamResponseModifications{}
@@ -344,7 +392,7 @@ func (p *PIIMaskingRegexPolicy) updatePayloadWithMaskedContent(originalPayload [
 }
 
 // buildErrorResponse builds an error response for both request and response phases
-func (p *PIIMaskingRegexPolicy) buildErrorResponse(reason string) interface{} {
+func (p *PIIMaskingRegexPolicy) buildErrorResponse(reason string, isResponse bool) interface{} {
 	responseBody := map[string]interface{}{
 		"code":    APIMInternalExceptionCode,
 		"message": "Error occurred during PIIMaskingRegex mediation: " + reason,
@@ -355,7 +403,17 @@ func (p *PIIMaskingRegexPolicy) buildErrorResponse(reason string) interface{} {
 		bodyBytes = []byte(fmt.Sprintf(`{"code":%d,"type":"PII_MASKING_REGEX","message":"Internal error"}`, APIMInternalExceptionCode))
 	}
 
-	// For PII masking, errors typically occur in request phase, but return as ImmediateResponse
+	if isResponse {
+		statusCode := APIMInternalErrorCode
+		return policy.UpstreamResponseModifications{
+			StatusCode: &statusCode,
+			Body:       bodyBytes,
+			SetHeaders: map[string]string{
+				"Content-Type": "application/json",
+			},
+		}
+	}
+
 	return policy.ImmediateResponse{
 		StatusCode: APIMInternalErrorCode,
 		Headers: map[string]string{
@@ -0,0 +1,371 @@
+package cors
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	WildcardOrigin = "*"
+	ReflectHeaders = "*"
+)
+
+// DefaultAllowedMethods are the methods allowed when allowedMethods is not configured
+var DefaultAllowedMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORSPolicy implements Cross-Origin Resource Sharing
+type CORSPolicy struct {
+	params CORSPolicyParams
+}
+
+type CORSPolicyParams struct {
+	AllowedOrigins          map[string]bool
+	AllowAnyOrigin          bool
+	AllowedOriginRegexes    []*regexp.Regexp
+	AllowedMethods          []string
+	AllowedHeaders          []string
+	ReflectRequestHeaders   bool
+	ExposeHeaders           []string
+	MaxAge                  int
+	HasMaxAge               bool
+	AllowCredentials        bool
+	RejectDisallowedOrigins bool
+}
+
+func GetPolicy(
+	metadata policy.PolicyMetadata,
+	params map[string]interface{},
+) (policy.Policy, error) {
+	policyParams, err := parseParams(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	return &CORSPolicy{params: policyParams}, nil
+}
+
+// parseParams parses and validates parameters from map to struct
+func parseParams(params map[string]interface{}) (CORSPolicyParams, error) {
+	result := CORSPolicyParams{
+		AllowedOrigins: make(map[string]bool),
+		AllowedMethods: DefaultAllowedMethods,
+	}
+
+	// Extract allowedOrigins parameter
+	if allowedOriginsRaw, ok := params["allowedOrigins"]; ok {
+		allowedOrigins, err := extractStringArray(allowedOriginsRaw)
+		if err != nil {
+			return result, fmt.Errorf("'allowedOrigins' %w", err)
+		}
+		for _, origin := range allowedOrigins {
+			if origin == WildcardOrigin {
+				result.AllowAnyOrigin = true
+				continue
+			}
+			result.AllowedOrigins[strings.ToLower(origin)] = true
+		}
+	}
+
+	// Extract allowedOriginRegexes parameter
+	if allowedOriginRegexesRaw, ok := params["allowedOriginRegexes"]; ok {
+		allowedOriginRegexes, err := extractStringArray(allowedOriginRegexesRaw)
+		if err != nil {
+			return result, fmt.Errorf("'allowedOriginRegexes' %w", err)
+		}
+		for i, pattern := range allowedOriginRegexes {
+			// Patterns must match the whole origin, so "https://example\.com" does not allow
+			// "https://example.com.attacker.net"
+			compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return result, fmt.Errorf("'allowedOriginRegexes[%d]' is invalid: %w", i, err)
+			}
+			result.AllowedOriginRegexes = append(result.AllowedOriginRegexes, compiled)
+		}
+	}
+
+	if !result.AllowAnyOrigin && len(result.AllowedOrigins) == 0 && len(result.AllowedOriginRegexes) == 0 {
+		return result, fmt.Errorf("at least one of 'allowedOrigins' or 'allowedOriginRegexes' must be provided")
+	}
+
+	// Extract optional allowedMethods parameter
+	if allowedMethodsRaw, ok := params["allowedMethods"]; ok {
+		allowedMethods, err := extractStringArray(allowedMethodsRaw)
+		if err != nil {
+			return result, fmt.Errorf("'allowedMethods' %w", err)
+		}
+		result.AllowedMethods = make([]string, 0, len(allowedMethods))
+		for _, method := range allowedMethods {
+			result.AllowedMethods = append(result.AllowedMethods, strings.ToUpper(method))
+		}
+	}
+
+	// Extract optional allowedHeaders parameter
+	if allowedHeadersRaw, ok := params["allowedHeaders"]; ok {
+		allowedHeaders, err := extractStringArray(allowedHeadersRaw)
+		if err != nil {
+			return result, fmt.Errorf("'allowedHeaders' %w", err)
+		}
+		for _, header := range allowedHeaders {
+			if header == ReflectHeaders {
+				result.ReflectRequestHeaders = true
+				continue
+			}
+			result.AllowedHeaders = append(result.AllowedHeaders, strings.ToLower(header))
+		}
+	}
+
+	// Extract optional exposeHeaders parameter
+	if exposeHeadersRaw, ok := params["exposeHeaders"]; ok {
+		exposeHeaders, err := extractStringArray(exposeHeadersRaw)
+		if err != nil {
+			return result, fmt.Errorf("'exposeHeaders' %w", err)
+		}
+		result.ExposeHeaders = exposeHeaders
+	}
+
+	// Extract optional maxAge parameter
+	if maxAgeRaw, ok := params["maxAge"]; ok {
+		maxAge, err := extractInt(maxAgeRaw)
+		if err != nil {
+			return result, fmt.Errorf("'maxAge' must be a number: %w", err)
+		}
+		if maxAge < 0 {
+			return result, fmt.Errorf("'maxAge' cannot be negative")
+		}
+		result.MaxAge = maxAge
+		result.HasMaxAge = true
+	}
+
+	// Extract optional allowCredentials parameter
+	if allowCredentialsRaw, ok := params["allowCredentials"]; ok {
+		if allowCredentials, ok := allowCredentialsRaw.(bool); ok {
+			result.AllowCredentials = allowCredentials
+		} else {
+			return result, fmt.Errorf("'allowCredentials' must be a boolean")
+		}
+	}
+
+	// Echoing any origin with credentials would let every site read credentialed responses
+	if result.AllowAnyOrigin && result.AllowCredentials {
+		return result, fmt.Errorf("'allowCredentials' cannot be used with the '*' origin; list the allowed origins instead")
+	}
+
+	// Extract optional rejectDisallowedOrigins parameter
+	if rejectRaw, ok := params["rejectDisallowedOrigins"]; ok {
+		if reject, ok := rejectRaw.(bool); ok {
+			result.RejectDisallowedOrigins = reject
+		} else {
+			return result, fmt.Errorf("'rejectDisallowedOrigins' must be a boolean")
+		}
+	}
+
+	return result, nil
+}
+
+// extractStringArray extracts an array of strings
+func extractStringArray(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	result := make([]string, 0, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("item %d must be a non-empty string", i)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// extractInt safely extracts an integer from various types
+func extractInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("expected an integer but got %v", v)
+		}
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to int", value)
+	}
+}
+
+// Mode returns the processing mode for this policy
+func (p *CORSPolicy) Mode() policy.ProcessingMode {
+	return policy.ProcessingMode{
+		RequestHeaderMode:  policy.HeaderModeProcess, // Need Origin and preflight headers
+		RequestBodyMode:    policy.BodyModeSkip,      // Don't need request body
+		ResponseHeaderMode: policy.HeaderModeProcess, // Adds CORS headers to responses
+		ResponseBodyMode:   policy.BodyModeSkip,      // Don't need response body
+	}
+}
+
+// OnRequest answers preflight requests and rejects disallowed origins
+func (p *CORSPolicy) OnRequest(ctx *policy.RequestContext, params map[string]interface{}) policy.RequestAction {
+	origin := firstHeader(ctx.Headers, "origin")
+	if origin == "" {
+		// Not a CORS request
+		return policy.UpstreamRequestModifications{}
+	}
+
+	if isPreflight(ctx) {
+		return p.handlePreflight(ctx, origin)
+	}
+
+	if !p.isOriginAllowed(origin) && p.params.RejectDisallowedOrigins {
+		return p.buildForbiddenResponse()
+	}
+
+	return policy.UpstreamRequestModifications{}
+}
+
+// OnResponse adds CORS headers to responses of actual (non-preflight) requests
+func (p *CORSPolicy) OnResponse(ctx *policy.ResponseContext, params map[string]interface{}) policy.ResponseAction {
+	origin := firstHeader(ctx.RequestHeaders, "origin")
+	if origin == "" {
+		return policy.UpstreamResponseModifications{}
+	}
+
+	if !p.isOriginAllowed(origin) {
+		// Responses still vary by origin even when no CORS headers are added
+		if p.varyByOrigin() {
+			return policy.UpstreamResponseModifications{
+				AppendHeaders: map[string][]string{"vary": {"Origin"}},
+			}
+		}
+		return policy.UpstreamResponseModifications{}
+	}
+
+	headers := p.allowOriginHeaders(origin)
+	if len(p.params.ExposeHeaders) > 0 {
+		headers["access-control-expose-headers"] = strings.Join(p.params.ExposeHeaders, ", ")
+	}
+
+	mods := policy.UpstreamResponseModifications{SetHeaders: headers}
+	if p.varyByOrigin() {
+		mods.AppendHeaders = map[string][]string{"vary": {"Origin"}}
+	}
+	return mods
+}
+
+// handlePreflight answers an OPTIONS preflight request without forwarding it upstream
+func (p *CORSPolicy) handlePreflight(ctx *policy.RequestContext, origin string) policy.RequestAction {
+	vary := []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}
+	headers := map[string]string{
+		"vary": strings.Join(vary, ", "),
+	}
+
+	if !p.isOriginAllowed(origin) {
+		if p.params.RejectDisallowedOrigins {
+			return p.buildForbiddenResponse()
+		}
+		// Without CORS headers the browser fails the preflight
+		return policy.ImmediateResponse{StatusCode: 204, Headers: headers}
+	}
+
+	for name, value := range p.allowOriginHeaders(origin) {
+		headers[name] = value
+	}
+	headers["access-control-allow-methods"] = strings.Join(p.params.AllowedMethods, ", ")
+
+	allowedHeaders := p.params.AllowedHeaders
+	if p.params.ReflectRequestHeaders {
+		allowedHeaders = requestedHeaders(ctx.Headers)
+	}
+	if len(allowedHeaders) > 0 {
+		headers["access-control-allow-headers"] = strings.Join(allowedHeaders, ", ")
+	}
+
+	if p.params.HasMaxAge {
+		headers["access-control-max-age"] = strconv.Itoa(p.params.MaxAge)
+	}
+
+	return policy.ImmediateResponse{
+		StatusCode: 204,
+		Headers:    headers,
+	}
+}
+
+// allowOriginHeaders builds the Allow-Origin and Allow-Credentials headers for an allowed origin.
+// The wildcard is returned when any origin is allowed; otherwise the origin is echoed.
+func (p *CORSPolicy) allowOriginHeaders(origin string) map[string]string {
+	headers := make(map[string]string)
+	if p.params.AllowAnyOrigin {
+		headers["access-control-allow-origin"] = WildcardOrigin
+	} else {
+		headers["access-control-allow-origin"] = origin
+	}
+	if p.params.AllowCredentials {
+		headers["access-control-allow-credentials"] = "true"
+	}
+	return headers
+}
+
+// varyByOrigin reports whether responses depend on the request Origin
+func (p *CORSPolicy) varyByOrigin() bool {
+	return !p.params.AllowAnyOrigin
+}
+
+// isOriginAllowed checks the origin against the exact and regex allow lists
+func (p *CORSPolicy) isOriginAllowed(origin string) bool {
+	if p.params.AllowAnyOrigin {
+		return true
+	}
+	if p.params.AllowedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+	for _, pattern := range p.params.AllowedOriginRegexes {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildForbiddenResponse builds the response returned for disallowed origins
+func (p *CORSPolicy) buildForbiddenResponse() policy.RequestAction {
+	return policy.ImmediateResponse{
+		StatusCode: 403,
+		Headers: map[string]string{
+			"content-type": "application/json",
+			"vary":         "Origin",
+		},
+		Body: []byte(`{"error": "Forbidden", "message": "Origin is not allowed"}`),
+	}
+}
+
+// isPreflight reports whether the request is a CORS preflight request
+func isPreflight(ctx *policy.RequestContext) bool {
+	return strings.EqualFold(ctx.Method, "OPTIONS") && firstHeader(ctx.Headers, "access-control-request-method") != ""
+}
+
+// requestedHeaders returns the headers listed in Access-Control-Request-Headers
+func requestedHeaders(headers *policy.Headers) []string {
+	var result []string
+	for _, value := range headers.Get("access-control-request-headers") {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				result = append(result, strings.ToLower(header))
+			}
+		}
+	}
+	return result
+}
+
+// firstHeader returns the first value of a header or an empty string
+func firstHeader(headers *policy.Headers, name string) string {
+	values := headers.Get(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
@@ -0,0 +1,241 @@
+package cors
+
+import (
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+func newCORSPolicy(t *testing.T, params map[string]interface{}) *CORSPolicy {
+	t.Helper()
+	p, err := GetPolicy(policy.PolicyMetadata{}, params)
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	return p.(*CORSPolicy)
+}
+
+func TestIsOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		origin  string
+		allowed bool
+	}{
+		{
+			name:    "exact origin",
+			params:  map[string]interface{}{"allowedOrigins": []interface{}{"https://app.example.com"}},
+			origin:  "https://app.example.com",
+			allowed: true,
+		},
+		{
+			name:    "exact origin is case-insensitive",
+			params:  map[string]interface{}{"allowedOrigins": []interface{}{"https://App.Example.com"}},
+			origin:  "https://app.example.COM",
+			allowed: true,
+		},
+		{
+			name:    "exact origin does not match a prefix",
+			params:  map[string]interface{}{"allowedOrigins": []interface{}{"https://app.example.com"}},
+			origin:  "https://app.example.com.attacker.net",
+			allowed: false,
+		},
+		{
+			name:    "wildcard",
+			params:  map[string]interface{}{"allowedOrigins": []interface{}{"*"}},
+			origin:  "https://anything.test",
+			allowed: true,
+		},
+		{
+			name:    "regex matches subdomain",
+			params:  map[string]interface{}{"allowedOriginRegexes": []interface{}{`https://[a-z0-9-]+\.example\.com`}},
+			origin:  "https://tenant-1.example.com",
+			allowed: true,
+		},
+		{
+			name:    "regex does not match suffix",
+			params:  map[string]interface{}{"allowedOriginRegexes": []interface{}{`https://[a-z0-9-]+\.example\.com`}},
+			origin:  "https://tenant.example.com.attacker.net",
+			allowed: false,
+		},
+		{
+			name:    "regex does not match prefix",
+			params:  map[string]interface{}{"allowedOriginRegexes": []interface{}{`https://[a-z0-9-]+\.example\.com`}},
+			origin:  "http://evil.test/https://a.example.com",
+			allowed: false,
+		},
+		{
+			name:    "regex alternation is anchored as a whole",
+			params:  map[string]interface{}{"allowedOriginRegexes": []interface{}{`https://a\.test|https://b\.test`}},
+			origin:  "https://b.test.attacker.net",
+			allowed: false,
+		},
+		{
+			name: "regex used when exact origins do not match",
+			params: map[string]interface{}{
+				"allowedOrigins":       []interface{}{"https://app.example.com"},
+				"allowedOriginRegexes": []interface{}{`https://.*\.internal\.test`},
+			},
+			origin:  "https://ci.internal.test",
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newCORSPolicy(t, tt.params)
+			if got := p.isOriginAllowed(tt.origin); got != tt.allowed {
+				t.Errorf("isOriginAllowed(%q) = %v, want %v", tt.origin, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestParseParamsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{
+			name:   "no origins",
+			params: map[string]interface{}{},
+		},
+		{
+			name:   "invalid regex",
+			params: map[string]interface{}{"allowedOriginRegexes": []interface{}{"("}},
+		},
+		{
+			name: "wildcard with credentials",
+			params: map[string]interface{}{
+				"allowedOrigins":   []interface{}{"https://app.example.com", "*"},
+				"allowCredentials": true,
+			},
+		},
+		{
+			name:   "negative maxAge",
+			params: map[string]interface{}{"allowedOrigins": []interface{}{"*"}, "maxAge": -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseParams(tt.params); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestOnResponseHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      map[string]interface{}
+		origin      string
+		allowOrigin string
+		credentials string
+		vary        bool
+	}{
+		{
+			name:        "wildcard",
+			params:      map[string]interface{}{"allowedOrigins": []interface{}{"*"}},
+			origin:      "https://anything.test",
+			allowOrigin: "*",
+		},
+		{
+			name: "credentials echo the origin",
+			params: map[string]interface{}{
+				"allowedOrigins":   []interface{}{"https://app.example.com"},
+				"allowCredentials": true,
+			},
+			origin:      "https://app.example.com",
+			allowOrigin: "https://app.example.com",
+			credentials: "true",
+			vary:        true,
+		},
+		{
+			name:   "disallowed origin only varies",
+			params: map[string]interface{}{"allowedOrigins": []interface{}{"https://app.example.com"}},
+			origin: "https://other.test",
+			vary:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newCORSPolicy(t, tt.params)
+			ctx := &policy.ResponseContext{
+				RequestHeaders: policy.NewHeaders(map[string][]string{"origin": {tt.origin}}),
+			}
+			mods, ok := p.OnResponse(ctx, nil).(policy.UpstreamResponseModifications)
+			if !ok {
+				t.Fatal("expected UpstreamResponseModifications")
+			}
+			if got := mods.SetHeaders["access-control-allow-origin"]; got != tt.allowOrigin {
+				t.Errorf("access-control-allow-origin = %q, want %q", got, tt.allowOrigin)
+			}
+			if got := mods.SetHeaders["access-control-allow-credentials"]; got != tt.credentials {
+				t.Errorf("access-control-allow-credentials = %q, want %q", got, tt.credentials)
+			}
+			if got := len(mods.AppendHeaders["vary"]) > 0; got != tt.vary {
+				t.Errorf("vary = %v, want %v", got, tt.vary)
+			}
+		})
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	p := newCORSPolicy(t, map[string]interface{}{
+		"allowedOriginRegexes":    []interface{}{`https://[a-z]+\.example\.com`},
+		"allowedHeaders":          []interface{}{"*"},
+		"maxAge":                  600,
+		"rejectDisallowedOrigins": true,
+	})
+
+	tests := []struct {
+		name    string
+		origin  string
+		status  int
+		headers map[string]string
+	}{
+		{
+			name:   "allowed origin",
+			origin: "https://app.example.com",
+			status: 204,
+			headers: map[string]string{
+				"access-control-allow-origin":  "https://app.example.com",
+				"access-control-allow-headers": "x-api-key, content-type",
+				"access-control-max-age":       "600",
+			},
+		},
+		{
+			name:   "suffix of an allowed origin",
+			origin: "https://app.example.com.attacker.net",
+			status: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &policy.RequestContext{
+				Method: "OPTIONS",
+				Headers: policy.NewHeaders(map[string][]string{
+					"origin":                         {tt.origin},
+					"access-control-request-method":  {"POST"},
+					"access-control-request-headers": {"X-Api-Key, Content-Type"},
+				}),
+			}
+			resp, ok := p.OnRequest(ctx, nil).(policy.ImmediateResponse)
+			if !ok {
+				t.Fatal("expected ImmediateResponse")
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			for name, want := range tt.headers {
+				if got := resp.Headers[name]; got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
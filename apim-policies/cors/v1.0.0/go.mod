@@ -0,0 +1,5 @@
+module github.com/renuka-fernando/api-platform-gateway-extensions/apim-policies/cors/v1.0.0
+
+go 1.23.0
+
+require github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
@@ -0,0 +1,409 @@
+package faultinjection
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	// Metadata key set by the BasicAuth policy, used for consumer targeting
+	MetadataKeyAuthUser = "auth.username"
+
+	DefaultAbortStatusCode = 503
+	DefaultHeaderValue     = "true"
+)
+
+// FaultInjectionPolicy injects delays and aborts into matching requests
+type FaultInjectionPolicy struct {
+	params FaultInjectionPolicyParams
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+type FaultInjectionPolicyParams struct {
+	Delay *DelayParams
+	Abort *AbortParams
+	Match MatchParams
+}
+
+// DelayParams configures a fixed or uniformly random delay
+type DelayParams struct {
+	Fixed      time.Duration
+	Min        time.Duration
+	Max        time.Duration
+	Percentage float64
+}
+
+// AbortParams configures the immediate response returned for aborted requests
+type AbortParams struct {
+	StatusCode int
+	Body       []byte
+	Headers    map[string]string
+	Percentage float64
+}
+
+// MatchParams selects the requests faults are injected into.
+// A request is targeted if it matches any configured selector; when no
+// selector is configured every request is targeted.
+type MatchParams struct {
+	HeaderName  string
+	HeaderValue string
+	Consumers   map[string]bool
+}
+
+func GetPolicy(
+	metadata policy.PolicyMetadata,
+	params map[string]interface{},
+) (policy.Policy, error) {
+	policyParams, err := parseParams(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	seed := time.Now().UnixNano()
+	if seedRaw, ok := params["seed"]; ok {
+		s, err := extractInt(seedRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters: 'seed' must be an integer: %w", err)
+		}
+		seed = int64(s)
+	}
+
+	return &FaultInjectionPolicy{
+		params: policyParams,
+		rnd:    rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// parseParams parses and validates parameters from map to struct
+func parseParams(params map[string]interface{}) (FaultInjectionPolicyParams, error) {
+	var result FaultInjectionPolicyParams
+
+	// Extract optional delay parameter
+	if delayRaw, ok := params["delay"]; ok {
+		delayMap, ok := delayRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'delay' must be an object")
+		}
+		delay, err := parseDelayParams(delayMap)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'delay': %w", err)
+		}
+		result.Delay = delay
+	}
+
+	// Extract optional abort parameter
+	if abortRaw, ok := params["abort"]; ok {
+		abortMap, ok := abortRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'abort' must be an object")
+		}
+		abort, err := parseAbortParams(abortMap)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'abort': %w", err)
+		}
+		result.Abort = abort
+	}
+
+	// At least one fault must be configured
+	if result.Delay == nil && result.Abort == nil {
+		return result, fmt.Errorf("at least one of 'delay' or 'abort' must be provided")
+	}
+
+	// Extract optional match parameter
+	if matchRaw, ok := params["match"]; ok {
+		matchMap, ok := matchRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'match' must be an object")
+		}
+		match, err := parseMatchParams(matchMap)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'match': %w", err)
+		}
+		result.Match = match
+	}
+
+	return result, nil
+}
+
+// parseDelayParams parses the delay configuration
+func parseDelayParams(params map[string]interface{}) (*DelayParams, error) {
+	result := &DelayParams{Percentage: 100}
+
+	_, hasFixed := params["fixedDelayMs"]
+	_, hasMin := params["minDelayMs"]
+	_, hasMax := params["maxDelayMs"]
+
+	switch {
+	case hasFixed && (hasMin || hasMax):
+		return nil, fmt.Errorf("'fixedDelayMs' cannot be combined with 'minDelayMs'/'maxDelayMs'")
+	case hasFixed:
+		fixed, err := extractInt(params["fixedDelayMs"])
+		if err != nil {
+			return nil, fmt.Errorf("'fixedDelayMs' must be a number: %w", err)
+		}
+		if fixed < 0 {
+			return nil, fmt.Errorf("'fixedDelayMs' cannot be negative")
+		}
+		result.Fixed = time.Duration(fixed) * time.Millisecond
+	case hasMin && hasMax:
+		min, err := extractInt(params["minDelayMs"])
+		if err != nil {
+			return nil, fmt.Errorf("'minDelayMs' must be a number: %w", err)
+		}
+		max, err := extractInt(params["maxDelayMs"])
+		if err != nil {
+			return nil, fmt.Errorf("'maxDelayMs' must be a number: %w", err)
+		}
+		if min < 0 {
+			return nil, fmt.Errorf("'minDelayMs' cannot be negative")
+		}
+		if min > max {
+			return nil, fmt.Errorf("'minDelayMs' cannot be greater than 'maxDelayMs'")
+		}
+		result.Min = time.Duration(min) * time.Millisecond
+		result.Max = time.Duration(max) * time.Millisecond
+	default:
+		return nil, fmt.Errorf("either 'fixedDelayMs' or both 'minDelayMs' and 'maxDelayMs' are required")
+	}
+
+	percentage, err := extractPercentage(params)
+	if err != nil {
+		return nil, err
+	}
+	result.Percentage = percentage
+
+	return result, nil
+}
+
+// parseAbortParams parses the abort configuration
+func parseAbortParams(params map[string]interface{}) (*AbortParams, error) {
+	result := &AbortParams{
+		StatusCode: DefaultAbortStatusCode,
+		Headers:    make(map[string]string),
+		Percentage: 100,
+	}
+
+	if statusCodeRaw, ok := params["statusCode"]; ok {
+		statusCode, err := extractInt(statusCodeRaw)
+		if err != nil {
+			return nil, fmt.Errorf("'statusCode' must be a number: %w", err)
+		}
+		if statusCode < 100 || statusCode > 599 {
+			return nil, fmt.Errorf("'statusCode' must be between 100 and 599")
+		}
+		result.StatusCode = statusCode
+	}
+
+	if bodyRaw, ok := params["body"]; ok {
+		body, ok := bodyRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'body' must be a string")
+		}
+		result.Body = []byte(body)
+	}
+
+	if headersRaw, ok := params["headers"]; ok {
+		headersList, ok := headersRaw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'headers' must be an array")
+		}
+		for i, headerRaw := range headersList {
+			headerMap, ok := headerRaw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'headers[%d]' must be an object", i)
+			}
+			name, ok := headerMap["name"].(string)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("'headers[%d].name' is required and must be a non-empty string", i)
+			}
+			value, ok := headerMap["value"].(string)
+			if !ok {
+				return nil, fmt.Errorf("'headers[%d].value' is required and must be a string", i)
+			}
+			result.Headers[name] = value
+		}
+	}
+
+	percentage, err := extractPercentage(params)
+	if err != nil {
+		return nil, err
+	}
+	result.Percentage = percentage
+
+	return result, nil
+}
+
+// parseMatchParams parses the request targeting configuration
+func parseMatchParams(params map[string]interface{}) (MatchParams, error) {
+	result := MatchParams{HeaderValue: DefaultHeaderValue}
+
+	if headerNameRaw, ok := params["headerName"]; ok {
+		headerName, ok := headerNameRaw.(string)
+		if !ok || headerName == "" {
+			return result, fmt.Errorf("'headerName' must be a non-empty string")
+		}
+		result.HeaderName = headerName
+	}
+
+	if headerValueRaw, ok := params["headerValue"]; ok {
+		headerValue, ok := headerValueRaw.(string)
+		if !ok {
+			return result, fmt.Errorf("'headerValue' must be a string")
+		}
+		result.HeaderValue = headerValue
+	}
+
+	if consumersRaw, ok := params["consumers"]; ok {
+		consumersList, ok := consumersRaw.([]interface{})
+		if !ok {
+			return result, fmt.Errorf("'consumers' must be an array")
+		}
+		result.Consumers = make(map[string]bool, len(consumersList))
+		for i, consumerRaw := range consumersList {
+			consumer, ok := consumerRaw.(string)
+			if !ok || consumer == "" {
+				return result, fmt.Errorf("'consumers[%d]' must be a non-empty string", i)
+			}
+			result.Consumers[consumer] = true
+		}
+	}
+
+	return result, nil
+}
+
+// extractPercentage extracts the optional percentage parameter (default 100)
+func extractPercentage(params map[string]interface{}) (float64, error) {
+	percentageRaw, ok := params["percentage"]
+	if !ok {
+		return 100, nil
+	}
+	var percentage float64
+	switch v := percentageRaw.(type) {
+	case float64:
+		percentage = v
+	case int:
+		percentage = float64(v)
+	case int64:
+		percentage = float64(v)
+	default:
+		return 0, fmt.Errorf("'percentage' must be a number")
+	}
+	if percentage < 0 || percentage > 100 {
+		return 0, fmt.Errorf("'percentage' must be between 0 and 100")
+	}
+	return percentage, nil
+}
+
+// extractInt safely extracts an integer from various types
+func extractInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("expected an integer but got %v", v)
+		}
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to int", value)
+	}
+}
+
+// Mode returns the processing mode for this policy
+func (p *FaultInjectionPolicy) Mode() policy.ProcessingMode {
+	return policy.ProcessingMode{
+		RequestHeaderMode:  policy.HeaderModeProcess, // Need request headers for targeting
+		RequestBodyMode:    policy.BodyModeSkip,      // Don't need request body
+		ResponseHeaderMode: policy.HeaderModeSkip,    // Faults are injected in request phase
+		ResponseBodyMode:   policy.BodyModeSkip,      // Faults are injected in request phase
+	}
+}
+
+// OnRequest delays and/or aborts the request if it is targeted
+func (p *FaultInjectionPolicy) OnRequest(ctx *policy.RequestContext, params map[string]interface{}) policy.RequestAction {
+	if !p.isTargeted(ctx) {
+		return policy.UpstreamRequestModifications{}
+	}
+
+	// Roll all dice up front so a given seed yields the same sequence of
+	// decisions regardless of how long the delay takes
+	delay, abort := p.roll()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	if abort {
+		headers := make(map[string]string, len(p.params.Abort.Headers))
+		for name, value := range p.params.Abort.Headers {
+			headers[name] = value
+		}
+		return policy.ImmediateResponse{
+			StatusCode: p.params.Abort.StatusCode,
+			Headers:    headers,
+			Body:       p.params.Abort.Body,
+		}
+	}
+
+	return policy.UpstreamRequestModifications{}
+}
+
+// OnResponse is not used by this policy (faults are injected in request phase)
+func (p *FaultInjectionPolicy) OnResponse(ctx *policy.ResponseContext, params map[string]interface{}) policy.ResponseAction {
+	return nil // No response processing needed
+}
+
+// isTargeted reports whether faults should be considered for the request
+func (p *FaultInjectionPolicy) isTargeted(ctx *policy.RequestContext) bool {
+	match := p.params.Match
+	if match.HeaderName == "" && len(match.Consumers) == 0 {
+		return true
+	}
+
+	if match.HeaderName != "" {
+		for _, value := range ctx.Headers.Get(match.HeaderName) {
+			if strings.EqualFold(strings.TrimSpace(value), match.HeaderValue) {
+				return true
+			}
+		}
+	}
+
+	if len(match.Consumers) > 0 {
+		if username, ok := ctx.Metadata[MetadataKeyAuthUser].(string); ok && match.Consumers[username] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// roll decides the delay to apply and whether to abort the request
+func (p *FaultInjectionPolicy) roll() (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var delay time.Duration
+	if d := p.params.Delay; d != nil && p.rnd.Float64()*100 < d.Percentage {
+		// Only one of Fixed or Min/Max is set
+		delay = d.Fixed + d.Min
+		if d.Max > d.Min {
+			delay += time.Duration(p.rnd.Int63n(int64(d.Max-d.Min) + 1))
+		}
+	}
+
+	abort := false
+	if a := p.params.Abort; a != nil && p.rnd.Float64()*100 < a.Percentage {
+		abort = true
+	}
+
+	return delay, abort
+}
@@ -0,0 +1,257 @@
+package faultinjection
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+// newTestPolicy builds a policy from its parameters, failing the test if they are invalid
+func newTestPolicy(t *testing.T, params map[string]interface{}) *FaultInjectionPolicy {
+	t.Helper()
+	p, err := GetPolicy(policy.PolicyMetadata{}, params)
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	return p.(*FaultInjectionPolicy)
+}
+
+// runRequest passes a request with the given headers and authenticated user through the policy
+func runRequest(p *FaultInjectionPolicy, headers map[string][]string, username string) policy.RequestAction {
+	metadata := map[string]interface{}{}
+	if username != "" {
+		metadata[MetadataKeyAuthUser] = username
+	}
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+	return p.OnRequest(&policy.RequestContext{
+		SharedContext: &policy.SharedContext{Metadata: metadata},
+		Headers:       policy.NewHeaders(headers),
+		Path:          "/pets",
+		Method:        "GET",
+	}, nil)
+}
+
+// aborted reports whether the action is the configured abort response
+func aborted(t *testing.T, action policy.RequestAction) bool {
+	t.Helper()
+	switch a := action.(type) {
+	case policy.ImmediateResponse:
+		return true
+	case policy.UpstreamRequestModifications:
+		return false
+	default:
+		t.Fatalf("unexpected action %T", a)
+		return false
+	}
+}
+
+func TestSeededDecisions(t *testing.T) {
+	const seed = 42
+	const requests = 50
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		// expect replays the draws of roll with the same seed
+		expect func(rnd *rand.Rand) (time.Duration, bool)
+	}{
+		{
+			name:   "abort only",
+			params: map[string]interface{}{"abort": map[string]interface{}{"percentage": 50}},
+			expect: func(rnd *rand.Rand) (time.Duration, bool) {
+				return 0, rnd.Float64()*100 < 50
+			},
+		},
+		{
+			name: "random delay and abort",
+			params: map[string]interface{}{
+				"delay": map[string]interface{}{"minDelayMs": 10, "maxDelayMs": 20, "percentage": 30},
+				"abort": map[string]interface{}{"percentage": 25},
+			},
+			expect: func(rnd *rand.Rand) (time.Duration, bool) {
+				var delay time.Duration
+				if rnd.Float64()*100 < 30 {
+					delay = 10*time.Millisecond + time.Duration(rnd.Int63n(int64(10*time.Millisecond)+1))
+				}
+				return delay, rnd.Float64()*100 < 25
+			},
+		},
+		{
+			name: "fixed delay and abort",
+			params: map[string]interface{}{
+				"delay": map[string]interface{}{"fixedDelayMs": 5, "percentage": 50},
+				"abort": map[string]interface{}{"percentage": 50},
+			},
+			expect: func(rnd *rand.Rand) (time.Duration, bool) {
+				var delay time.Duration
+				if rnd.Float64()*100 < 50 {
+					delay = 5 * time.Millisecond
+				}
+				return delay, rnd.Float64()*100 < 50
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params["seed"] = seed
+			p := newTestPolicy(t, tt.params)
+			again := newTestPolicy(t, tt.params)
+			rnd := rand.New(rand.NewSource(seed))
+
+			aborts := 0
+			for i := 0; i < requests; i++ {
+				wantDelay, wantAbort := tt.expect(rnd)
+				delay, abort := p.roll()
+				if delay != wantDelay || abort != wantAbort {
+					t.Fatalf("request %d: roll() = %v, %v, want %v, %v", i, delay, abort, wantDelay, wantAbort)
+				}
+				if againDelay, againAbort := again.roll(); againDelay != delay || againAbort != abort {
+					t.Fatalf("request %d: policies with the same seed diverged", i)
+				}
+				if abort {
+					aborts++
+				}
+			}
+			// A partial percentage must yield a mix of decisions
+			if aborts == 0 || aborts == requests {
+				t.Errorf("%d of %d requests aborted, want a mix", aborts, requests)
+			}
+		})
+	}
+}
+
+func TestPercentageBoundaries(t *testing.T) {
+	const requests = 100
+
+	tests := []struct {
+		name       string
+		percentage interface{}
+		wantAborts int
+	}{
+		{name: "zero never aborts", percentage: 0, wantAborts: 0},
+		{name: "hundred always aborts", percentage: 100, wantAborts: requests},
+		{name: "default always aborts", wantAborts: requests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			abort := map[string]interface{}{"statusCode": 500}
+			delay := map[string]interface{}{"fixedDelayMs": 0}
+			if tt.percentage != nil {
+				abort["percentage"] = tt.percentage
+				delay["percentage"] = tt.percentage
+			}
+			p := newTestPolicy(t, map[string]interface{}{"abort": abort, "delay": delay, "seed": 7})
+
+			aborts := 0
+			for i := 0; i < requests; i++ {
+				action := runRequest(p, nil, "")
+				if aborted(t, action) {
+					if got := action.(policy.ImmediateResponse).StatusCode; got != 500 {
+						t.Fatalf("status = %d, want 500", got)
+					}
+					aborts++
+				}
+			}
+			if aborts != tt.wantAborts {
+				t.Errorf("%d of %d requests aborted, want %d", aborts, requests, tt.wantAborts)
+			}
+		})
+	}
+}
+
+func TestTargeting(t *testing.T) {
+	tests := []struct {
+		name     string
+		match    map[string]interface{}
+		headers  map[string][]string
+		username string
+		aborted  bool
+	}{
+		{
+			name:    "no selector targets every request",
+			aborted: true,
+		},
+		{
+			name:    "header with default value",
+			match:   map[string]interface{}{"headerName": "x-fault"},
+			headers: map[string][]string{"x-fault": {" TRUE "}},
+			aborted: true,
+		},
+		{
+			name:    "header with another value",
+			match:   map[string]interface{}{"headerName": "x-fault"},
+			headers: map[string][]string{"x-fault": {"false"}},
+			aborted: false,
+		},
+		{
+			name:    "header with configured value",
+			match:   map[string]interface{}{"headerName": "x-fault", "headerValue": "chaos"},
+			headers: map[string][]string{"x-fault": {"Chaos"}},
+			aborted: true,
+		},
+		{
+			name:    "missing header",
+			match:   map[string]interface{}{"headerName": "x-fault"},
+			aborted: false,
+		},
+		{
+			name:     "listed consumer",
+			match:    map[string]interface{}{"consumers": []interface{}{"alice", "bob"}},
+			username: "bob",
+			aborted:  true,
+		},
+		{
+			name:     "unlisted consumer",
+			match:    map[string]interface{}{"consumers": []interface{}{"alice"}},
+			username: "mallory",
+			aborted:  false,
+		},
+		{
+			name:    "unauthenticated request with consumers",
+			match:   map[string]interface{}{"consumers": []interface{}{"alice"}},
+			aborted: false,
+		},
+		{
+			name:     "consumer matches without the header",
+			match:    map[string]interface{}{"headerName": "x-fault", "consumers": []interface{}{"alice"}},
+			username: "alice",
+			aborted:  true,
+		},
+		{
+			name:     "header matches without the consumer",
+			match:    map[string]interface{}{"headerName": "x-fault", "consumers": []interface{}{"alice"}},
+			headers:  map[string][]string{"x-fault": {"true"}},
+			username: "mallory",
+			aborted:  true,
+		},
+		{
+			name:     "neither header nor consumer matches",
+			match:    map[string]interface{}{"headerName": "x-fault", "consumers": []interface{}{"alice"}},
+			headers:  map[string][]string{"x-fault": {"no"}},
+			username: "mallory",
+			aborted:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				"abort": map[string]interface{}{"percentage": 100},
+				"seed":  1,
+			}
+			if tt.match != nil {
+				params["match"] = tt.match
+			}
+			p := newTestPolicy(t, params)
+			if got := aborted(t, runRequest(p, tt.headers, tt.username)); got != tt.aborted {
+				t.Errorf("aborted = %v, want %v", got, tt.aborted)
+			}
+		})
+	}
+}
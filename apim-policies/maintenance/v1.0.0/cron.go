@@ -0,0 +1,138 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed 5-field cron expression: minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minutes     [60]bool
+	hours       [24]bool
+	daysOfMonth [32]bool
+	months      [13]bool
+	daysOfWeek  [7]bool
+	// Cron semantics: if both day fields are restricted, a day matches if either matches
+	domRestricted bool
+	dowRestricted bool
+}
+
+// parseCron parses a cron expression supporting *, values, ranges (a-b), lists (a,b) and steps (*/n, a-b/n)
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week) but got %d", len(fields))
+	}
+
+	c := &cronSchedule{}
+	if err := parseCronField(fields[0], 0, 59, c.minutes[:]); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, c.hours[:]); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, c.daysOfMonth[:]); err != nil {
+		return nil, fmt.Errorf("invalid day-of-month field: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, c.months[:]); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	// Day of week accepts 0-7, where both 0 and 7 are Sunday
+	var daysOfWeek [8]bool
+	if err := parseCronField(fields[4], 0, 7, daysOfWeek[:]); err != nil {
+		return nil, fmt.Errorf("invalid day-of-week field: %w", err)
+	}
+	copy(c.daysOfWeek[:], daysOfWeek[:7])
+	c.daysOfWeek[0] = c.daysOfWeek[0] || daysOfWeek[7]
+
+	c.domRestricted = fields[2] != "*"
+	c.dowRestricted = fields[4] != "*"
+	return c, nil
+}
+
+// parseCronField sets the values matched by a single cron field
+func parseCronField(field string, min, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepPart)
+			if err != nil || s <= 0 {
+				return fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = s
+		}
+
+		var from, to int
+		switch {
+		case rangePart == "*":
+			from, to = min, max
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return fmt.Errorf("invalid value %q", a)
+			}
+			if to, err = strconv.Atoi(b); err != nil {
+				return fmt.Errorf("invalid value %q", b)
+			}
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return fmt.Errorf("invalid value %q", rangePart)
+			}
+			from, to = v, v
+			if hasStep {
+				to = max
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+	return nil
+}
+
+// matchesDay reports whether the schedule fires on the day of t
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	if !c.months[t.Month()] {
+		return false
+	}
+	dom := c.daysOfMonth[t.Day()]
+	dow := c.daysOfWeek[t.Weekday()]
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// prev returns the latest time at or before t (in t's location) the schedule fires,
+// searching no further back than limit
+func (c *cronSchedule) prev(t, limit time.Time) (time.Time, bool) {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	for !t.Before(limit) {
+		if !c.matchesDay(t) {
+			// Skip to the last minute of the previous day
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).Add(-time.Minute)
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			// Skip to the last minute of the previous hour
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc).Add(-time.Minute)
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(-time.Minute)
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
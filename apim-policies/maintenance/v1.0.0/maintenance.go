@@ -0,0 +1,377 @@
+package maintenance
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embed the time zone database for gateways without system zoneinfo
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	// Metadata key set by authentication policies, used for consumer bypass
+	MetadataKeyAuthUser = "auth.username"
+
+	MaintenanceStatusCode = 503
+	DefaultBody           = `{"error": "Service Unavailable", "message": "The API is under scheduled maintenance", "retryAfter": ${retryAfter}}`
+
+	// Placeholders available in body templates
+	PlaceholderRetryAfter = "${retryAfter}"
+	PlaceholderWindowEnd  = "${windowEnd}"
+	PlaceholderWindowName = "${windowName}"
+
+	// Upper bound on the windows followed when chaining back-to-back windows
+	maxChainedWindows = 100
+)
+
+// localTimeLayouts are accepted for window start/end without a UTC offset
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// MaintenancePolicy returns 503 while a scheduled maintenance window is active
+type MaintenancePolicy struct {
+	params MaintenancePolicyParams
+	// now returns the current time, overridable for tests
+	now func() time.Time
+}
+
+type MaintenancePolicyParams struct {
+	Windows         []*maintenanceWindow
+	Body            string
+	Headers         map[string]string
+	BypassConsumers map[string]bool
+	BypassHeader    string
+	BypassValue     string
+}
+
+// maintenanceWindow is either a one-off window (start/end) or a recurring one (schedule/duration)
+type maintenanceWindow struct {
+	name     string
+	start    time.Time
+	end      time.Time
+	schedule *cronSchedule
+	duration time.Duration
+	location *time.Location
+}
+
+func GetPolicy(
+	metadata policy.PolicyMetadata,
+	params map[string]interface{},
+) (policy.Policy, error) {
+	policyParams, err := parseParams(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	return &MaintenancePolicy{params: policyParams, now: time.Now}, nil
+}
+
+// parseParams parses and validates parameters from map to struct
+func parseParams(params map[string]interface{}) (MaintenancePolicyParams, error) {
+	result := MaintenancePolicyParams{
+		Body:            DefaultBody,
+		Headers:         map[string]string{"content-type": "application/json"},
+		BypassConsumers: make(map[string]bool),
+	}
+
+	// Validate and extract windows parameter (required)
+	windowsList, ok := params["windows"].([]interface{})
+	if !ok || len(windowsList) == 0 {
+		return result, fmt.Errorf("'windows' is required and must be a non-empty array")
+	}
+	for i, windowRaw := range windowsList {
+		windowMap, ok := windowRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'windows[%d]' must be an object", i)
+		}
+		window, err := parseWindow(windowMap)
+		if err != nil {
+			return result, fmt.Errorf("invalid 'windows[%d]': %w", i, err)
+		}
+		if window.name == "" {
+			window.name = fmt.Sprintf("window-%d", i)
+		}
+		result.Windows = append(result.Windows, window)
+	}
+
+	// Extract optional body parameter
+	if bodyRaw, ok := params["body"]; ok {
+		body, ok := bodyRaw.(string)
+		if !ok {
+			return result, fmt.Errorf("'body' must be a string")
+		}
+		result.Body = body
+	}
+
+	// Extract optional headers parameter
+	if headersRaw, ok := params["headers"]; ok {
+		headersList, ok := headersRaw.([]interface{})
+		if !ok {
+			return result, fmt.Errorf("'headers' must be an array")
+		}
+		for i, headerRaw := range headersList {
+			headerMap, ok := headerRaw.(map[string]interface{})
+			if !ok {
+				return result, fmt.Errorf("'headers[%d]' must be an object", i)
+			}
+			name, ok := headerMap["name"].(string)
+			if !ok || name == "" {
+				return result, fmt.Errorf("'headers[%d].name' is required and must be a non-empty string", i)
+			}
+			value, ok := headerMap["value"].(string)
+			if !ok {
+				return result, fmt.Errorf("'headers[%d].value' is required and must be a string", i)
+			}
+			result.Headers[strings.ToLower(name)] = value
+		}
+	}
+
+	// Extract optional bypassConsumers parameter
+	if bypassConsumersRaw, ok := params["bypassConsumers"]; ok {
+		consumersList, ok := bypassConsumersRaw.([]interface{})
+		if !ok {
+			return result, fmt.Errorf("'bypassConsumers' must be an array")
+		}
+		for i, consumerRaw := range consumersList {
+			consumer, ok := consumerRaw.(string)
+			if !ok || consumer == "" {
+				return result, fmt.Errorf("'bypassConsumers[%d]' must be a non-empty string", i)
+			}
+			result.BypassConsumers[consumer] = true
+		}
+	}
+
+	// Extract optional bypassHeader parameter
+	if bypassHeaderRaw, ok := params["bypassHeader"]; ok {
+		bypassHeaderMap, ok := bypassHeaderRaw.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("'bypassHeader' must be an object")
+		}
+		name, ok := bypassHeaderMap["name"].(string)
+		if !ok || name == "" {
+			return result, fmt.Errorf("'bypassHeader.name' is required and must be a non-empty string")
+		}
+		value, ok := bypassHeaderMap["value"].(string)
+		if !ok || value == "" {
+			return result, fmt.Errorf("'bypassHeader.value' is required and must be a non-empty string")
+		}
+		result.BypassHeader = name
+		result.BypassValue = value
+	}
+
+	return result, nil
+}
+
+// parseWindow parses and validates a maintenance window
+func parseWindow(params map[string]interface{}) (*maintenanceWindow, error) {
+	w := &maintenanceWindow{location: time.UTC}
+
+	if nameRaw, ok := params["name"]; ok {
+		name, ok := nameRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'name' must be a string")
+		}
+		w.name = name
+	}
+
+	if timezoneRaw, ok := params["timezone"]; ok {
+		timezone, ok := timezoneRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'timezone' must be a string")
+		}
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("'timezone' is invalid: %w", err)
+		}
+		w.location = location
+	}
+
+	startRaw, hasStart := params["start"]
+	endRaw, hasEnd := params["end"]
+	scheduleRaw, hasSchedule := params["schedule"]
+	durationRaw, hasDuration := params["duration"]
+
+	switch {
+	case hasSchedule && (hasStart || hasEnd):
+		return nil, fmt.Errorf("'schedule' cannot be combined with 'start'/'end'")
+	case hasStart && hasEnd:
+		start, err := parseWindowTime(startRaw, w.location)
+		if err != nil {
+			return nil, fmt.Errorf("'start' %w", err)
+		}
+		end, err := parseWindowTime(endRaw, w.location)
+		if err != nil {
+			return nil, fmt.Errorf("'end' %w", err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("'end' must be after 'start'")
+		}
+		w.start = start
+		w.end = end
+	case hasSchedule:
+		expr, ok := scheduleRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'schedule' must be a string")
+		}
+		schedule, err := parseCron(expr)
+		if err != nil {
+			return nil, fmt.Errorf("'schedule' is invalid: %w", err)
+		}
+		if !hasDuration {
+			return nil, fmt.Errorf("'duration' is required with 'schedule'")
+		}
+		durationStr, ok := durationRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'duration' must be a string such as \"2h\" or \"30m\"")
+		}
+		duration, err := time.ParseDuration(durationStr)
+		if err != nil {
+			return nil, fmt.Errorf("'duration' is invalid: %w", err)
+		}
+		if duration < time.Minute {
+			return nil, fmt.Errorf("'duration' must be at least 1m")
+		}
+		w.schedule = schedule
+		w.duration = duration
+	default:
+		return nil, fmt.Errorf("either 'start' and 'end', or 'schedule' and 'duration' are required")
+	}
+
+	return w, nil
+}
+
+// parseWindowTime parses an RFC 3339 time, or a local time in the window's time zone
+func parseWindowTime(value interface{}, location *time.Location) (time.Time, error) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("must be a string")
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("must be an RFC 3339 time or a local time such as 2006-01-02T15:04")
+}
+
+// activeUntil returns the end of the window occurrence active at now
+func (w *maintenanceWindow) activeUntil(now time.Time) (time.Time, bool) {
+	if w.schedule == nil {
+		if !now.Before(w.start) && now.Before(w.end) {
+			return w.end, true
+		}
+		return time.Time{}, false
+	}
+
+	local := now.In(w.location)
+	start, ok := w.schedule.prev(local, local.Add(-w.duration))
+	if !ok {
+		return time.Time{}, false
+	}
+	end := start.Add(w.duration)
+	if now.Before(end) {
+		return end, true
+	}
+	return time.Time{}, false
+}
+
+// Mode returns the processing mode for this policy
+func (p *MaintenancePolicy) Mode() policy.ProcessingMode {
+	return policy.ProcessingMode{
+		RequestHeaderMode:  policy.HeaderModeProcess, // Need bypass header
+		RequestBodyMode:    policy.BodyModeSkip,      // Don't need request body
+		ResponseHeaderMode: policy.HeaderModeSkip,    // Returns immediate response
+		ResponseBodyMode:   policy.BodyModeSkip,      // Returns immediate response
+	}
+}
+
+// OnRequest returns 503 while a maintenance window is active
+func (p *MaintenancePolicy) OnRequest(ctx *policy.RequestContext, params map[string]interface{}) policy.RequestAction {
+	now := p.now()
+
+	// Find the active window ending last
+	var active *maintenanceWindow
+	var end time.Time
+	for _, w := range p.params.Windows {
+		if windowEnd, ok := w.activeUntil(now); ok && windowEnd.After(end) {
+			active, end = w, windowEnd
+		}
+	}
+	if active == nil || p.isBypassed(ctx) {
+		return policy.UpstreamRequestModifications{}
+	}
+	end = p.chainedEnd(end)
+
+	retryAfter := int(math.Ceil(end.Sub(now).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	headers := make(map[string]string, len(p.params.Headers)+1)
+	for name, value := range p.params.Headers {
+		headers[name] = value
+	}
+	headers["retry-after"] = strconv.Itoa(retryAfter)
+
+	replacer := strings.NewReplacer(
+		PlaceholderRetryAfter, strconv.Itoa(retryAfter),
+		PlaceholderWindowEnd, end.In(active.location).Format(time.RFC3339),
+		PlaceholderWindowName, active.name,
+	)
+
+	return policy.ImmediateResponse{
+		StatusCode: MaintenanceStatusCode,
+		Headers:    headers,
+		Body:       []byte(replacer.Replace(p.params.Body)),
+	}
+}
+
+// chainedEnd extends end across windows that start at or before it, so Retry-After
+// covers back-to-back and overlapping windows. Schedules that keep chaining, such as
+// one firing every minute for a minute, are followed for at most maxChainedWindows.
+func (p *MaintenancePolicy) chainedEnd(end time.Time) time.Time {
+	for i := 0; i < maxChainedWindows; i++ {
+		next := end
+		for _, w := range p.params.Windows {
+			if windowEnd, ok := w.activeUntil(end); ok && windowEnd.After(next) {
+				next = windowEnd
+			}
+		}
+		if !next.After(end) {
+			break
+		}
+		end = next
+	}
+	return end
+}
+
+// OnResponse is not used by this policy (returns immediate response in request phase)
+func (p *MaintenancePolicy) OnResponse(ctx *policy.ResponseContext, params map[string]interface{}) policy.ResponseAction {
+	return nil // No response processing needed
+}
+
+// isBypassed reports whether the request may pass through during maintenance
+func (p *MaintenancePolicy) isBypassed(ctx *policy.RequestContext) bool {
+	if p.params.BypassHeader != "" {
+		for _, value := range ctx.Headers.Get(p.params.BypassHeader) {
+			if value == p.params.BypassValue {
+				return true
+			}
+		}
+	}
+	if len(p.params.BypassConsumers) > 0 {
+		if username, ok := ctx.Metadata[MetadataKeyAuthUser].(string); ok && p.params.BypassConsumers[username] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package maintenance
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+// newTestPolicy builds a policy from its parameters with a fixed clock
+func newTestPolicy(t *testing.T, params map[string]interface{}, now time.Time) *MaintenancePolicy {
+	t.Helper()
+	p, err := GetPolicy(policy.PolicyMetadata{}, params)
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	mp := p.(*MaintenancePolicy)
+	mp.now = func() time.Time { return now }
+	return mp
+}
+
+// mustParse parses an RFC 3339 time, failing the test if it is invalid
+func mustParse(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("time.Parse(%q): %v", value, err)
+	}
+	return parsed
+}
+
+func TestCronPrev(t *testing.T) {
+	// 2024-01-10 is a Wednesday
+	tests := []struct {
+		name     string
+		schedule string
+		at       string
+		limit    time.Duration
+		want     string
+	}{
+		{name: "current minute", schedule: "* * * * *", at: "2024-01-10T10:30:45Z", want: "2024-01-10T10:30:00Z"},
+		{name: "minute step", schedule: "*/15 * * * *", at: "2024-01-10T10:37:00Z", want: "2024-01-10T10:30:00Z"},
+		{name: "earlier the same day", schedule: "0 2 * * *", at: "2024-01-10T10:30:00Z", want: "2024-01-10T02:00:00Z"},
+		{name: "previous day", schedule: "0 2 * * *", at: "2024-01-10T01:00:00Z", want: "2024-01-09T02:00:00Z"},
+		{name: "hour range and list", schedule: "15,45 8-9 * * *", at: "2024-01-10T10:30:00Z", want: "2024-01-10T09:45:00Z"},
+		{name: "day of week", schedule: "30 1 * * 0", at: "2024-01-10T10:30:00Z", want: "2024-01-07T01:30:00Z"},
+		{name: "sunday as 7", schedule: "0 12 * * 7", at: "2024-01-10T10:30:00Z", want: "2024-01-07T12:00:00Z"},
+		{name: "day of month or day of week", schedule: "0 0 15 * 1", at: "2024-01-10T10:30:00Z", want: "2024-01-08T00:00:00Z"},
+		{name: "previous month", schedule: "0 0 31 * *", at: "2024-01-10T10:30:00Z", want: "2023-12-31T00:00:00Z"},
+		{name: "previous year", schedule: "0 0 1 6 *", at: "2024-01-10T10:30:00Z", limit: 366 * 24 * time.Hour, want: "2023-06-01T00:00:00Z"},
+		{name: "nothing within the limit", schedule: "0 0 1 1 *", at: "2024-01-10T10:30:00Z", limit: 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCron(tt.schedule)
+			if err != nil {
+				t.Fatalf("parseCron: %v", err)
+			}
+			at := mustParse(t, tt.at)
+			limit := tt.limit
+			if limit == 0 {
+				limit = 31 * 24 * time.Hour
+			}
+			got, ok := schedule.prev(at, at.Add(-limit))
+			if tt.want == "" {
+				if ok {
+					t.Errorf("prev = %v, want none", got)
+				}
+				return
+			}
+			if !ok || !got.Equal(mustParse(t, tt.want)) {
+				t.Errorf("prev = %v, %v, want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowTimeZones(t *testing.T) {
+	tests := []struct {
+		name   string
+		window map[string]interface{}
+		now    string
+		until  string
+	}{
+		{
+			name:   "recurring window in its time zone",
+			window: map[string]interface{}{"schedule": "0 22 * * *", "duration": "2h", "timezone": "America/New_York"},
+			now:    "2024-01-11T04:00:00Z",
+			until:  "2024-01-11T05:00:00Z",
+		},
+		{
+			name:   "recurring window inactive at the same UTC time",
+			window: map[string]interface{}{"schedule": "0 22 * * *", "duration": "2h", "timezone": "America/New_York"},
+			now:    "2024-01-10T22:30:00Z",
+		},
+		{
+			name:   "recurring window across midnight",
+			window: map[string]interface{}{"schedule": "30 23 * * *", "duration": "1h", "timezone": "Asia/Kolkata"},
+			now:    "2024-01-10T18:45:00Z",
+			until:  "2024-01-10T19:00:00Z",
+		},
+		{
+			name:   "local one-off window across a DST change",
+			window: map[string]interface{}{"start": "2024-03-10T01:00", "end": "2024-03-10T04:00", "timezone": "America/New_York"},
+			now:    "2024-03-10T07:30:00Z",
+			until:  "2024-03-10T08:00:00Z",
+		},
+		{
+			name:   "offset overrides the time zone",
+			window: map[string]interface{}{"start": "2024-01-10T10:00:00Z", "end": "2024-01-10T11:00:00Z", "timezone": "Asia/Tokyo"},
+			now:    "2024-01-10T10:30:00Z",
+			until:  "2024-01-10T11:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := parseWindow(tt.window)
+			if err != nil {
+				t.Fatalf("parseWindow: %v", err)
+			}
+			until, ok := w.activeUntil(mustParse(t, tt.now))
+			if tt.until == "" {
+				if ok {
+					t.Errorf("active until %v, want inactive", until)
+				}
+				return
+			}
+			if !ok || !until.Equal(mustParse(t, tt.until)) {
+				t.Errorf("activeUntil = %v, %v, want %s", until, ok, tt.until)
+			}
+		})
+	}
+}
+
+func TestOnRequest(t *testing.T) {
+	window := func(name, start, end string) interface{} {
+		return map[string]interface{}{"name": name, "start": start, "end": end}
+	}
+
+	tests := []struct {
+		name       string
+		windows    []interface{}
+		body       string
+		now        string
+		headers    map[string][]string
+		retryAfter int // 0 when the request passes through
+		wantBody   string
+	}{
+		{
+			name:    "outside every window",
+			windows: []interface{}{window("a", "2024-01-10T10:00:00Z", "2024-01-10T11:00:00Z")},
+			now:     "2024-01-10T11:00:00Z",
+		},
+		{
+			name:       "default body",
+			windows:    []interface{}{window("a", "2024-01-10T10:00:00Z", "2024-01-10T11:00:00Z")},
+			now:        "2024-01-10T10:30:00Z",
+			retryAfter: 1800,
+			wantBody:   `{"error": "Service Unavailable", "message": "The API is under scheduled maintenance", "retryAfter": 1800}`,
+		},
+		{
+			name: "templated body in the window time zone",
+			windows: []interface{}{map[string]interface{}{
+				"name": "db-upgrade", "start": "2024-01-10T15:00", "end": "2024-01-10T16:00", "timezone": "Asia/Colombo",
+			}},
+			body:       "${windowName} until ${windowEnd} (${retryAfter}s)",
+			now:        "2024-01-10T10:00:00Z",
+			retryAfter: 1800,
+			wantBody:   "db-upgrade until 2024-01-10T16:00:00+05:30 (1800s)",
+		},
+		{
+			name:       "partial seconds round up",
+			windows:    []interface{}{window("a", "2024-01-10T10:00:00Z", "2024-01-10T11:00:00Z")},
+			body:       "${retryAfter}",
+			now:        "2024-01-10T10:58:29.5Z",
+			retryAfter: 91,
+			wantBody:   "91",
+		},
+		{
+			name: "back-to-back windows",
+			windows: []interface{}{
+				window("a", "2024-01-10T10:00:00Z", "2024-01-10T11:00:00Z"),
+				window("b", "2024-01-10T11:00:00Z", "2024-01-10T12:00:00Z"),
+			},
+			body:       "${windowName} ${windowEnd}",
+			now:        "2024-01-10T10:30:00Z",
+			retryAfter: 5400,
+			wantBody:   "a 2024-01-10T12:00:00Z",
+		},
+		{
+			name: "overlapping chain of windows",
+			windows: []interface{}{
+				window("c", "2024-01-10T11:30:00Z", "2024-01-10T12:00:00Z"),
+				window("b", "2024-01-10T10:45:00Z", "2024-01-10T11:30:00Z"),
+				window("a", "2024-01-10T10:00:00Z", "2024-01-10T11:00:00Z"),
+			},
+			now:        "2024-01-10T10:30:00Z",
+			retryAfter: 5400,
+		},
+		{
+			name: "gap between windows",
+			windows: []interface{}{
+				window("a", "2024-01-10T10:00:00Z", "2024-01-10T11:00:00Z"),
+				window("b", "2024-01-10T11:01:00Z", "2024-01-10T12:00:00Z"),
+			},
+			now:        "2024-01-10T10:30:00Z",
+			retryAfter: 1800,
+		},
+		{
+			name: "recurring window followed by a one-off window",
+			windows: []interface{}{
+				map[string]interface{}{"schedule": "0 10 * * *", "duration": "1h"},
+				window("b", "2024-01-10T11:00:00Z", "2024-01-10T11:30:00Z"),
+			},
+			now:        "2024-01-10T10:30:00Z",
+			retryAfter: 3600,
+		},
+		{
+			name:       "chaining is bounded for continuous schedules",
+			windows:    []interface{}{map[string]interface{}{"schedule": "0 * * * *", "duration": "1h"}},
+			now:        "2024-01-10T10:30:00Z",
+			retryAfter: 1800 + maxChainedWindows*3600,
+		},
+		{
+			name:    "bypass header",
+			windows: []interface{}{window("a", "2024-01-10T10:00:00Z", "2024-01-10T11:00:00Z")},
+			now:     "2024-01-10T10:30:00Z",
+			headers: map[string][]string{"x-bypass": {"secret"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				"windows":      tt.windows,
+				"bypassHeader": map[string]interface{}{"name": "x-bypass", "value": "secret"},
+			}
+			if tt.body != "" {
+				params["body"] = tt.body
+			}
+			now, err := time.Parse(time.RFC3339Nano, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := newTestPolicy(t, params, now)
+			headers := tt.headers
+			if headers == nil {
+				headers = map[string][]string{}
+			}
+			action := p.OnRequest(&policy.RequestContext{
+				SharedContext: &policy.SharedContext{Metadata: map[string]interface{}{}},
+				Headers:       policy.NewHeaders(headers),
+			}, nil)
+
+			resp, ok := action.(policy.ImmediateResponse)
+			if tt.retryAfter == 0 {
+				if ok {
+					t.Fatalf("status = %d, want the request passed through", resp.StatusCode)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("action = %T, want an immediate response", action)
+			}
+			if resp.StatusCode != MaintenanceStatusCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, MaintenanceStatusCode)
+			}
+			if got := resp.Headers["retry-after"]; got != strconv.Itoa(tt.retryAfter) {
+				t.Errorf("retry-after = %s, want %d", got, tt.retryAfter)
+			}
+			if tt.wantBody != "" && string(resp.Body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", resp.Body, tt.wantBody)
+			}
+		})
+	}
+}
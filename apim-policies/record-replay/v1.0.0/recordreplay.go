@@ -0,0 +1,430 @@
+package recordreplay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+
+	OnMissPassThrough = "passThrough"
+	OnMissNotFound    = "notFound"
+
+	// Metadata key carrying the request fingerprint from the request to the response phase
+	MetadataKeyFingerprint = "recordreplay:fingerprint"
+
+	fixtureFileExt = ".json"
+)
+
+// skippedResponseHeaders are not recorded as they are recomputed when the fixture is served
+var skippedResponseHeaders = map[string]bool{
+	"content-length":    true,
+	"transfer-encoding": true,
+	"connection":        true,
+	"keep-alive":        true,
+	"date":              true,
+}
+
+// RecordReplayPolicy records upstream responses into fixture files and replays them
+type RecordReplayPolicy struct {
+	params RecordReplayPolicyParams
+}
+
+type RecordReplayPolicyParams struct {
+	Mode         string
+	Directory    string
+	MatchQuery   bool
+	MatchHeaders []string
+	MatchBody    bool
+	OnMiss       string
+	Overwrite    bool
+}
+
+// fixture is the on-disk representation of a recorded exchange
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+// fixtureRequest is the fingerprinted part of the request, kept for readability of fixtures
+type fixtureRequest struct {
+	Fingerprint string            `json:"fingerprint"`
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Query       string            `json:"query,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	BodySHA256  string            `json:"bodySha256,omitempty"`
+}
+
+type fixtureResponse struct {
+	StatusCode   int               `json:"statusCode"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         string            `json:"body,omitempty"`
+	BodyEncoding string            `json:"bodyEncoding,omitempty"`
+}
+
+func GetPolicy(
+	metadata policy.PolicyMetadata,
+	params map[string]interface{},
+) (policy.Policy, error) {
+	policyParams, err := parseParams(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if policyParams.Mode == ModeRecord {
+		if err := os.MkdirAll(policyParams.Directory, 0o755); err != nil {
+			return nil, fmt.Errorf("cannot create fixture directory: %w", err)
+		}
+	}
+
+	return &RecordReplayPolicy{params: policyParams}, nil
+}
+
+// parseParams parses and validates parameters from map to struct
+func parseParams(params map[string]interface{}) (RecordReplayPolicyParams, error) {
+	result := RecordReplayPolicyParams{
+		MatchQuery: true,
+		OnMiss:     OnMissNotFound,
+		Overwrite:  true,
+	}
+
+	// Validate and extract mode parameter (required)
+	mode, ok := params["mode"].(string)
+	if !ok || (mode != ModeRecord && mode != ModeReplay) {
+		return result, fmt.Errorf("'mode' is required and must be either %q or %q", ModeRecord, ModeReplay)
+	}
+	result.Mode = mode
+
+	// Validate and extract directory parameter (required)
+	directory, ok := params["directory"].(string)
+	if !ok || directory == "" {
+		return result, fmt.Errorf("'directory' is required and must be a non-empty string")
+	}
+	result.Directory = directory
+
+	// Extract optional matchQuery parameter
+	if matchQueryRaw, ok := params["matchQuery"]; ok {
+		if matchQuery, ok := matchQueryRaw.(bool); ok {
+			result.MatchQuery = matchQuery
+		} else {
+			return result, fmt.Errorf("'matchQuery' must be a boolean")
+		}
+	}
+
+	// Extract optional matchHeaders parameter
+	if matchHeadersRaw, ok := params["matchHeaders"]; ok {
+		headersList, ok := matchHeadersRaw.([]interface{})
+		if !ok {
+			return result, fmt.Errorf("'matchHeaders' must be an array")
+		}
+		for i, headerRaw := range headersList {
+			header, ok := headerRaw.(string)
+			if !ok || header == "" {
+				return result, fmt.Errorf("'matchHeaders[%d]' must be a non-empty string", i)
+			}
+			result.MatchHeaders = append(result.MatchHeaders, strings.ToLower(header))
+		}
+		sort.Strings(result.MatchHeaders)
+	}
+
+	// Extract optional matchBody parameter
+	if matchBodyRaw, ok := params["matchBody"]; ok {
+		if matchBody, ok := matchBodyRaw.(bool); ok {
+			result.MatchBody = matchBody
+		} else {
+			return result, fmt.Errorf("'matchBody' must be a boolean")
+		}
+	}
+
+	// Extract optional onMiss parameter
+	if onMissRaw, ok := params["onMiss"]; ok {
+		onMiss, ok := onMissRaw.(string)
+		if !ok || (onMiss != OnMissPassThrough && onMiss != OnMissNotFound) {
+			return result, fmt.Errorf("'onMiss' must be either %q or %q", OnMissPassThrough, OnMissNotFound)
+		}
+		result.OnMiss = onMiss
+	}
+
+	// Extract optional overwrite parameter
+	if overwriteRaw, ok := params["overwrite"]; ok {
+		if overwrite, ok := overwriteRaw.(bool); ok {
+			result.Overwrite = overwrite
+		} else {
+			return result, fmt.Errorf("'overwrite' must be a boolean")
+		}
+	}
+
+	return result, nil
+}
+
+// Mode returns the processing mode for this policy
+func (p *RecordReplayPolicy) Mode() policy.ProcessingMode {
+	requestBodyMode := policy.BodyModeSkip
+	if p.params.MatchBody {
+		requestBodyMode = policy.BodyModeBuffer
+	}
+
+	if p.params.Mode == ModeRecord {
+		return policy.ProcessingMode{
+			RequestHeaderMode:  policy.HeaderModeProcess, // Fingerprints the request
+			RequestBodyMode:    requestBodyMode,          // Body hash is part of the fingerprint
+			ResponseHeaderMode: policy.HeaderModeProcess, // Records upstream headers
+			ResponseBodyMode:   policy.BodyModeBuffer,    // Records upstream body
+		}
+	}
+	return policy.ProcessingMode{
+		RequestHeaderMode:  policy.HeaderModeProcess, // Fingerprints the request
+		RequestBodyMode:    requestBodyMode,          // Body hash is part of the fingerprint
+		ResponseHeaderMode: policy.HeaderModeSkip,    // Returns immediate response
+		ResponseBodyMode:   policy.BodyModeSkip,      // Returns immediate response
+	}
+}
+
+// OnRequest fingerprints the request and, in replay mode, serves the recorded response
+func (p *RecordReplayPolicy) OnRequest(ctx *policy.RequestContext, params map[string]interface{}) policy.RequestAction {
+	var body []byte
+	if ctx.Body != nil {
+		body = ctx.Body.Content
+	}
+	req := p.fingerprint(ctx.Method, ctx.Path, ctx.Headers, body)
+
+	if p.params.Mode == ModeRecord {
+		// Fingerprint as seen at this point of the chain; the response phase writes the fixture
+		ctx.Metadata[MetadataKeyFingerprint] = req
+		return policy.UpstreamRequestModifications{}
+	}
+
+	f, err := p.loadFixture(req.Fingerprint)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return p.handleMiss(req)
+		}
+		return p.buildErrorResponse("Cannot load the recorded response", err, req, false).(policy.RequestAction)
+	}
+
+	responseBody := []byte(f.Response.Body)
+	if f.Response.BodyEncoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(f.Response.Body)
+		if err != nil {
+			return p.buildErrorResponse("Cannot decode the recorded response body", err, req, false).(policy.RequestAction)
+		}
+		responseBody = decoded
+	}
+
+	headers := make(map[string]string, len(f.Response.Headers))
+	for name, value := range f.Response.Headers {
+		headers[name] = value
+	}
+	return policy.ImmediateResponse{
+		StatusCode: f.Response.StatusCode,
+		Headers:    headers,
+		Body:       responseBody,
+	}
+}
+
+// OnResponse records the upstream response in record mode
+func (p *RecordReplayPolicy) OnResponse(ctx *policy.ResponseContext, params map[string]interface{}) policy.ResponseAction {
+	if p.params.Mode != ModeRecord {
+		return nil // No response processing needed
+	}
+
+	req, ok := ctx.Metadata[MetadataKeyFingerprint].(fixtureRequest)
+	if !ok {
+		return policy.UpstreamResponseModifications{}
+	}
+
+	resp := fixtureResponse{
+		StatusCode: ctx.ResponseStatus,
+		Headers:    make(map[string]string),
+	}
+	ctx.ResponseHeaders.Iterate(func(name string, values []string) {
+		if strings.HasPrefix(name, ":") || skippedResponseHeaders[name] {
+			return
+		}
+		resp.Headers[name] = strings.Join(values, ", ")
+	})
+	if ctx.ResponseBody != nil && len(ctx.ResponseBody.Content) > 0 {
+		if utf8.Valid(ctx.ResponseBody.Content) {
+			resp.Body = string(ctx.ResponseBody.Content)
+		} else {
+			resp.Body = base64.StdEncoding.EncodeToString(ctx.ResponseBody.Content)
+			resp.BodyEncoding = "base64"
+		}
+	}
+
+	// A response that cannot be recorded fails loudly, so the recording session is not silently incomplete
+	if err := p.saveFixture(fixture{Request: req, Response: resp}); err != nil {
+		return p.buildErrorResponse("Cannot record the upstream response", err, req, true).(policy.ResponseAction)
+	}
+
+	return policy.UpstreamResponseModifications{}
+}
+
+// fingerprint builds the request fingerprint from the parts selected by the matching rules
+func (p *RecordReplayPolicy) fingerprint(method, rawPath string, headers *policy.Headers, body []byte) fixtureRequest {
+	path, rawQuery, _ := strings.Cut(rawPath, "?")
+	req := fixtureRequest{
+		Method: strings.ToUpper(method),
+		Path:   path,
+	}
+
+	if p.params.MatchQuery && rawQuery != "" {
+		// Normalize parameter order so equivalent queries match
+		if query, err := url.ParseQuery(rawQuery); err == nil {
+			req.Query = query.Encode()
+		} else {
+			req.Query = rawQuery
+		}
+	}
+
+	if len(p.params.MatchHeaders) > 0 {
+		req.Headers = make(map[string]string, len(p.params.MatchHeaders))
+		for _, name := range p.params.MatchHeaders {
+			req.Headers[name] = strings.Join(headers.Get(name), ", ")
+		}
+	}
+
+	if p.params.MatchBody {
+		sum := sha256.Sum256(body)
+		req.BodySHA256 = hex.EncodeToString(sum[:])
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", req.Method, req.Path, req.Query)
+	for _, name := range p.params.MatchHeaders {
+		fmt.Fprintf(h, "%s: %s\n", name, req.Headers[name])
+	}
+	fmt.Fprintf(h, "%s\n", req.BodySHA256)
+	req.Fingerprint = hex.EncodeToString(h.Sum(nil))
+
+	return req
+}
+
+// handleMiss handles a replay request with no recorded fixture
+func (p *RecordReplayPolicy) handleMiss(req fixtureRequest) policy.RequestAction {
+	if p.params.OnMiss == OnMissPassThrough {
+		return policy.UpstreamRequestModifications{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"error":       "Not Found",
+		"message":     "No recorded response matches the request",
+		"fingerprint": req.Fingerprint,
+	})
+	if err != nil {
+		body = []byte(`{"error": "Not Found", "message": "No recorded response matches the request"}`)
+	}
+	return policy.ImmediateResponse{
+		StatusCode: 404,
+		Headers: map[string]string{
+			"content-type": "application/json",
+		},
+		Body: body,
+	}
+}
+
+// buildErrorResponse builds the 500 response returned when a fixture cannot be read or written.
+// The error is only logged, as file errors carry server paths that clients must not see.
+func (p *RecordReplayPolicy) buildErrorResponse(message string, err error, req fixtureRequest, isResponse bool) interface{} {
+	slog.Error(message, "fingerprint", req.Fingerprint, "error", err)
+
+	body, marshalErr := json.Marshal(map[string]interface{}{
+		"error":       "Internal Server Error",
+		"message":     message,
+		"fingerprint": req.Fingerprint,
+	})
+	if marshalErr != nil {
+		body = []byte(`{"error": "Internal Server Error", "message": "Fixture error"}`)
+	}
+	headers := map[string]string{
+		"content-type": "application/json",
+	}
+
+	if isResponse {
+		statusCode := 500
+		return policy.UpstreamResponseModifications{
+			StatusCode: &statusCode,
+			SetHeaders: headers,
+			Body:       body,
+		}
+	}
+	return policy.ImmediateResponse{
+		StatusCode: 500,
+		Headers:    headers,
+		Body:       body,
+	}
+}
+
+// fixturePath returns the fixture file path for a fingerprint
+func (p *RecordReplayPolicy) fixturePath(fingerprint string) string {
+	return filepath.Join(p.params.Directory, fingerprint+fixtureFileExt)
+}
+
+// loadFixture reads the fixture recorded for a fingerprint
+func (p *RecordReplayPolicy) loadFixture(fingerprint string) (*fixture, error) {
+	data, err := os.ReadFile(p.fixturePath(fingerprint))
+	if err != nil {
+		return nil, err
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture file: %w", err)
+	}
+	return &f, nil
+}
+
+// saveFixture writes a fixture atomically so concurrent replays never read partial files
+func (p *RecordReplayPolicy) saveFixture(f fixture) error {
+	path := p.fixturePath(f.Request.Fingerprint)
+	if !p.params.Overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+
+	// Fixtures are meant to be read and edited, so keep &, < and > unescaped
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(p.params.Directory, ".fixture-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// Temp files are created private; fixtures are shared with other readers
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
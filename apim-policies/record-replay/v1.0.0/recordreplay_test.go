@@ -0,0 +1,316 @@
+package recordreplay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+// newTestPolicy builds a policy from its parameters, failing the test if they are invalid
+func newTestPolicy(t *testing.T, params map[string]interface{}) *RecordReplayPolicy {
+	t.Helper()
+	p, err := GetPolicy(policy.PolicyMetadata{}, params)
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	return p.(*RecordReplayPolicy)
+}
+
+// exchange is a request and the upstream response recorded for it
+type exchange struct {
+	method  string
+	path    string
+	headers map[string][]string
+	body    string
+
+	status          int
+	responseHeaders map[string][]string
+	responseBody    []byte
+}
+
+// record passes the exchange through a record mode policy
+func record(t *testing.T, p *RecordReplayPolicy, e exchange) policy.ResponseAction {
+	t.Helper()
+	shared := &policy.SharedContext{Metadata: map[string]interface{}{}}
+	p.OnRequest(&policy.RequestContext{
+		SharedContext: shared,
+		Headers:       policy.NewHeaders(e.headers),
+		Body:          &policy.Body{Content: []byte(e.body), EndOfStream: true, Present: true},
+		Path:          e.path,
+		Method:        e.method,
+	}, nil)
+	return p.OnResponse(&policy.ResponseContext{
+		SharedContext:   shared,
+		RequestHeaders:  policy.NewHeaders(e.headers),
+		ResponseHeaders: policy.NewHeaders(e.responseHeaders),
+		ResponseBody:    &policy.Body{Content: e.responseBody, EndOfStream: true, Present: true},
+		ResponseStatus:  e.status,
+	}, nil)
+}
+
+// replay passes the request of the exchange through a replay mode policy
+func replay(p *RecordReplayPolicy, e exchange) policy.RequestAction {
+	return p.OnRequest(&policy.RequestContext{
+		SharedContext: &policy.SharedContext{Metadata: map[string]interface{}{}},
+		Headers:       policy.NewHeaders(e.headers),
+		Body:          &policy.Body{Content: []byte(e.body), EndOfStream: true, Present: true},
+		Path:          e.path,
+		Method:        e.method,
+	}, nil)
+}
+
+func TestFingerprint(t *testing.T) {
+	params := map[string]interface{}{
+		"mode":         ModeReplay,
+		"directory":    t.TempDir(),
+		"matchHeaders": []interface{}{"X-Tenant", "accept"},
+		"matchBody":    true,
+	}
+	base := newTestPolicy(t, params).fingerprint("GET", "/pets?b=2&a=1", policy.NewHeaders(map[string][]string{
+		"x-tenant": {"acme"},
+		"accept":   {"application/json"},
+	}), []byte("body"))
+
+	tests := []struct {
+		name         string
+		matchHeaders []interface{}
+		method       string
+		path         string
+		headers      map[string][]string
+		body         string
+		same         bool
+	}{
+		{
+			name:    "query parameter order",
+			method:  "GET",
+			path:    "/pets?a=1&b=2",
+			headers: map[string][]string{"x-tenant": {"acme"}, "accept": {"application/json"}},
+			body:    "body",
+			same:    true,
+		},
+		{
+			name:         "matchHeaders order and case",
+			matchHeaders: []interface{}{"ACCEPT", "x-tenant"},
+			method:       "get",
+			path:         "/pets?b=2&a=1",
+			headers:      map[string][]string{"accept": {"application/json"}, "x-tenant": {"acme"}},
+			body:         "body",
+			same:         true,
+		},
+		{
+			name:    "unmatched header",
+			method:  "GET",
+			path:    "/pets?b=2&a=1",
+			headers: map[string][]string{"x-tenant": {"acme"}, "accept": {"application/json"}, "x-request-id": {"1"}},
+			body:    "body",
+			same:    true,
+		},
+		{
+			name:    "query value",
+			method:  "GET",
+			path:    "/pets?b=2&a=3",
+			headers: map[string][]string{"x-tenant": {"acme"}, "accept": {"application/json"}},
+			body:    "body",
+			same:    false,
+		},
+		{
+			name:    "matched header value",
+			method:  "GET",
+			path:    "/pets?b=2&a=1",
+			headers: map[string][]string{"x-tenant": {"other"}, "accept": {"application/json"}},
+			body:    "body",
+			same:    false,
+		},
+		{
+			name:    "body",
+			method:  "GET",
+			path:    "/pets?b=2&a=1",
+			headers: map[string][]string{"x-tenant": {"acme"}, "accept": {"application/json"}},
+			body:    "other body",
+			same:    false,
+		},
+		{
+			name:    "method",
+			method:  "POST",
+			path:    "/pets?b=2&a=1",
+			headers: map[string][]string{"x-tenant": {"acme"}, "accept": {"application/json"}},
+			body:    "body",
+			same:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variant := map[string]interface{}{}
+			for k, v := range params {
+				variant[k] = v
+			}
+			if tt.matchHeaders != nil {
+				variant["matchHeaders"] = tt.matchHeaders
+			}
+			got := newTestPolicy(t, variant).fingerprint(tt.method, tt.path, policy.NewHeaders(tt.headers), []byte(tt.body))
+			if (got.Fingerprint == base.Fingerprint) != tt.same {
+				t.Errorf("fingerprint equal = %v, want %v", got.Fingerprint == base.Fingerprint, tt.same)
+			}
+		})
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange exchange
+	}{
+		{
+			name: "json response",
+			exchange: exchange{
+				method:          "GET",
+				path:            "/pets?limit=2",
+				headers:         map[string][]string{"accept": {"application/json"}},
+				status:          200,
+				responseHeaders: map[string][]string{"content-type": {"application/json"}, "content-length": {"18"}, "x-trace": {"a", "b"}},
+				responseBody:    []byte(`[{"name": "<Rex>"}]`),
+			},
+		},
+		{
+			name: "binary response",
+			exchange: exchange{
+				method:          "GET",
+				path:            "/logo.png",
+				status:          200,
+				responseHeaders: map[string][]string{"content-type": {"image/png"}},
+				responseBody:    []byte{0x89, 'P', 'N', 'G', 0xff, 0x00},
+			},
+		},
+		{
+			name: "error response without body",
+			exchange: exchange{
+				method: "DELETE",
+				path:   "/pets/1",
+				status: 404,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			recorder := newTestPolicy(t, map[string]interface{}{"mode": ModeRecord, "directory": dir})
+			if tt.exchange.responseHeaders == nil {
+				tt.exchange.responseHeaders = map[string][]string{}
+			}
+			if tt.exchange.headers == nil {
+				tt.exchange.headers = map[string][]string{}
+			}
+			if mods, ok := record(t, recorder, tt.exchange).(policy.UpstreamResponseModifications); !ok || mods.StatusCode != nil {
+				t.Fatal("expected the upstream response to be passed on")
+			}
+
+			replayer := newTestPolicy(t, map[string]interface{}{"mode": ModeReplay, "directory": dir})
+			resp, ok := replay(replayer, tt.exchange).(policy.ImmediateResponse)
+			if !ok {
+				t.Fatal("expected the recorded response")
+			}
+			if resp.StatusCode != tt.exchange.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.exchange.status)
+			}
+			if string(resp.Body) != string(tt.exchange.responseBody) {
+				t.Errorf("body = %q, want %q", resp.Body, tt.exchange.responseBody)
+			}
+			for name, values := range tt.exchange.responseHeaders {
+				want := strings.Join(values, ", ")
+				if skippedResponseHeaders[name] {
+					want = ""
+				}
+				if got := resp.Headers[name]; got != want {
+					t.Errorf("header %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFixtureFileMode(t *testing.T) {
+	dir := t.TempDir()
+	recorder := newTestPolicy(t, map[string]interface{}{"mode": ModeRecord, "directory": dir})
+	record(t, recorder, exchange{
+		method:          "GET",
+		path:            "/pets",
+		headers:         map[string][]string{},
+		status:          200,
+		responseHeaders: map[string][]string{},
+		responseBody:    []byte("ok"),
+	})
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fixtureFileExt))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("fixture files = %v, %v, want one", files, err)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0o644 {
+		t.Errorf("fixture mode = %o, want 644", got)
+	}
+}
+
+func TestReplayMissAndErrors(t *testing.T) {
+	request := exchange{method: "GET", path: "/pets", headers: map[string][]string{}}
+
+	tests := []struct {
+		name       string
+		onMiss     string
+		fixture    string
+		unreadable bool
+		status     int
+	}{
+		{name: "miss returns 404", onMiss: OnMissNotFound, status: 404},
+		{name: "miss passes through", onMiss: OnMissPassThrough, status: 0},
+		{name: "corrupt fixture returns 500", onMiss: OnMissNotFound, fixture: "{not json", status: 500},
+		{name: "unreadable fixture returns 500", onMiss: OnMissNotFound, unreadable: true, status: 500},
+		{name: "undecodable body returns 500", onMiss: OnMissNotFound,
+			fixture: `{"response": {"statusCode": 200, "body": "%%%", "bodyEncoding": "base64"}}`, status: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := newTestPolicy(t, map[string]interface{}{"mode": ModeReplay, "directory": dir, "onMiss": tt.onMiss})
+			path := p.fixturePath(p.fingerprint(request.method, request.path, policy.NewHeaders(request.headers), nil).Fingerprint)
+			if tt.fixture != "" {
+				if err := os.WriteFile(path, []byte(tt.fixture), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.unreadable {
+				// Reading a directory fails with an error naming its path
+				if err := os.Mkdir(path, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			action := replay(p, request)
+			if tt.status == 0 {
+				if _, ok := action.(policy.UpstreamRequestModifications); !ok {
+					t.Fatalf("action = %T, want the request passed on", action)
+				}
+				return
+			}
+			resp, ok := action.(policy.ImmediateResponse)
+			if !ok {
+				t.Fatalf("action = %T, want an immediate response", action)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			// File errors name server paths, which are logged but never sent to clients
+			if strings.Contains(string(resp.Body), dir) {
+				t.Errorf("body %s exposes the fixture directory", resp.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Command harcheck reports HAR entries that would be ambiguous when served by the
+// Respond policy, i.e. entries sharing a match key of which only the first is served.
+//
+// Usage:
+//
+//	harcheck [-match-query=true] [-match-body=false] session.har
+//
+// Exits with status 1 if entries with conflicting responses are found.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	respond "github.com/renuka-fernando/api-platform-gateway-extensions/apim-policies/respond/v1.0.0"
+)
+
+func main() {
+	matchQuery := flag.Bool("match-query", true, "match entries by query string")
+	matchBody := flag.Bool("match-body", false, "match entries by request body")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file.har>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ambiguities, err := respond.CheckHAR(flag.Arg(0), *matchQuery, *matchBody)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(ambiguities) == 0 {
+		fmt.Println("no ambiguous entries found")
+		return
+	}
+
+	conflicts := 0
+	for _, a := range ambiguities {
+		target := a.Path
+		if a.Query != "" {
+			target += "?" + a.Query
+		}
+		kind := "duplicate"
+		if a.ConflictingResponses {
+			kind = "CONFLICT"
+			conflicts++
+		}
+		fmt.Printf("%-9s %s %s: entries %v (entry %d is served)\n", kind, a.Method, target, a.Entries, a.Entries[0])
+	}
+	fmt.Printf("%d ambiguous match keys, %d with conflicting responses\n", len(ambiguities), conflicts)
+
+	if conflicts > 0 {
+		os.Exit(1)
+	}
+}
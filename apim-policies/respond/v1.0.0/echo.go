@@ -0,0 +1,174 @@
+package respond
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	RedactedValue = "[REDACTED]"
+)
+
+// DefaultRedactedHeaders are the credential carrying headers redacted in echo responses
+var DefaultRedactedHeaders = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+}
+
+// DefaultRedactedMetadataKeys are metadata entries redacted in echo responses.
+// The PIIMaskingRegex policy stores the original PII values under this key.
+var DefaultRedactedMetadataKeys = []string{
+	"piimaskingregex:pii_entities",
+}
+
+// echoConfig configures the request echo responder
+type echoConfig struct {
+	redactHeaders      map[string]bool
+	redactMetadataKeys map[string]bool
+	includeBody        bool
+	includeMetadata    bool
+}
+
+// parseEchoConfig parses and validates the echo configuration
+func parseEchoConfig(params map[string]interface{}) (*echoConfig, error) {
+	e := &echoConfig{
+		redactHeaders:      toSet(DefaultRedactedHeaders, true),
+		redactMetadataKeys: toSet(DefaultRedactedMetadataKeys, false),
+		includeBody:        true,
+		includeMetadata:    true,
+	}
+
+	if redactHeadersRaw, ok := params["redactHeaders"]; ok {
+		redactHeaders, err := toStringSlice(redactHeadersRaw)
+		if err != nil {
+			return nil, fmt.Errorf("'redactHeaders' %w", err)
+		}
+		e.redactHeaders = toSet(redactHeaders, true)
+	}
+
+	if redactMetadataKeysRaw, ok := params["redactMetadataKeys"]; ok {
+		redactMetadataKeys, err := toStringSlice(redactMetadataKeysRaw)
+		if err != nil {
+			return nil, fmt.Errorf("'redactMetadataKeys' %w", err)
+		}
+		e.redactMetadataKeys = toSet(redactMetadataKeys, false)
+	}
+
+	if includeBodyRaw, ok := params["includeBody"]; ok {
+		includeBody, ok := includeBodyRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'includeBody' must be a boolean")
+		}
+		e.includeBody = includeBody
+	}
+
+	if includeMetadataRaw, ok := params["includeMetadata"]; ok {
+		includeMetadata, ok := includeMetadataRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'includeMetadata' must be a boolean")
+		}
+		e.includeMetadata = includeMetadata
+	}
+
+	return e, nil
+}
+
+// buildEchoBody builds the JSON document describing the request as received by the gateway
+func (e *echoConfig) buildEchoBody(ctx *policy.RequestContext) []byte {
+	path, rawQuery, _ := strings.Cut(ctx.Path, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		query = url.Values{}
+	}
+
+	headers := ctx.Headers.GetAll()
+	for name, values := range headers {
+		if e.redactHeaders[strings.ToLower(name)] {
+			for i := range values {
+				values[i] = RedactedValue
+			}
+		}
+	}
+
+	echo := map[string]interface{}{
+		"method":    ctx.Method,
+		"path":      path,
+		"query":     query,
+		"authority": ctx.Authority,
+		"scheme":    ctx.Scheme,
+		"headers":   headers,
+	}
+	if ctx.SharedContext != nil {
+		echo["requestId"] = ctx.RequestID
+	}
+
+	if e.includeBody && ctx.Body != nil && ctx.Body.Present {
+		if utf8.Valid(ctx.Body.Content) {
+			echo["body"] = string(ctx.Body.Content)
+		} else {
+			echo["body"] = base64.StdEncoding.EncodeToString(ctx.Body.Content)
+			echo["bodyEncoding"] = "base64"
+		}
+	}
+
+	if e.includeMetadata && ctx.SharedContext != nil {
+		metadata := make(map[string]interface{}, len(ctx.Metadata))
+		for key, value := range ctx.Metadata {
+			if e.redactMetadataKeys[key] {
+				metadata[key] = RedactedValue
+				continue
+			}
+			// Values written by other policies may not be JSON serializable
+			if _, err := json.Marshal(value); err != nil {
+				metadata[key] = fmt.Sprintf("%v", value)
+				continue
+			}
+			metadata[key] = value
+		}
+		echo["metadata"] = metadata
+	}
+
+	body, err := json.Marshal(echo)
+	if err != nil {
+		return []byte(`{"error": "Internal error", "message": "Failed to build echo response"}`)
+	}
+	return body
+}
+
+// toStringSlice converts an array parameter to a slice of strings
+func toStringSlice(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// toSet builds a lookup set, optionally lower-casing the entries
+func toSet(values []string, lower bool) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if lower {
+			v = strings.ToLower(v)
+		}
+		set[v] = true
+	}
+	return set
+}
@@ -2,4 +2,7 @@ module github.com/renuka-fernando/api-platform-gateway-extensions/apim-policies/
 
 go 1.23.0
 
-require github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
+require (
+	github.com/wso2/api-platform/sdk v0.0.0-20251218061802-e63558346492
+	google.golang.org/protobuf v1.36.11
+)
@@ -0,0 +1,247 @@
+package respond
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	GRPCContentType = "application/grpc"
+)
+
+// grpcCodes maps gRPC status code names to their values
+var grpcCodes = map[string]int{
+	"OK":                  0,
+	"CANCELLED":           1,
+	"UNKNOWN":             2,
+	"INVALID_ARGUMENT":    3,
+	"DEADLINE_EXCEEDED":   4,
+	"NOT_FOUND":           5,
+	"ALREADY_EXISTS":      6,
+	"PERMISSION_DENIED":   7,
+	"RESOURCE_EXHAUSTED":  8,
+	"FAILED_PRECONDITION": 9,
+	"ABORTED":             10,
+	"OUT_OF_RANGE":        11,
+	"UNIMPLEMENTED":       12,
+	"INTERNAL":            13,
+	"UNAVAILABLE":         14,
+	"DATA_LOSS":           15,
+	"UNAUTHENTICATED":     16,
+}
+
+// defaultGRPCStatusMapping maps HTTP status codes to the gRPC code with the closest meaning
+var defaultGRPCStatusMapping = map[int]int{
+	400: 3,  // INVALID_ARGUMENT
+	401: 16, // UNAUTHENTICATED
+	403: 7,  // PERMISSION_DENIED
+	404: 5,  // NOT_FOUND
+	408: 4,  // DEADLINE_EXCEEDED
+	409: 10, // ABORTED
+	412: 9,  // FAILED_PRECONDITION
+	413: 8,  // RESOURCE_EXHAUSTED
+	422: 3,  // INVALID_ARGUMENT
+	429: 8,  // RESOURCE_EXHAUSTED
+	499: 1,  // CANCELLED
+	500: 13, // INTERNAL
+	501: 12, // UNIMPLEMENTED
+	502: 14, // UNAVAILABLE
+	503: 14, // UNAVAILABLE
+	504: 4,  // DEADLINE_EXCEEDED
+}
+
+// grpcConfig configures gRPC immediate responses
+type grpcConfig struct {
+	status        int // -1 = derived from the HTTP status code
+	message       string
+	frame         []byte
+	statusMapping map[int]int
+}
+
+// parseGRPCConfig parses and validates the gRPC configuration.
+// The protobuf message is encoded once here so requests only copy the frame.
+func parseGRPCConfig(params map[string]interface{}) (*grpcConfig, error) {
+	g := &grpcConfig{
+		status:        -1,
+		statusMapping: make(map[int]int, len(defaultGRPCStatusMapping)),
+	}
+	for httpStatus, grpcStatus := range defaultGRPCStatusMapping {
+		g.statusMapping[httpStatus] = grpcStatus
+	}
+
+	if statusRaw, ok := params["status"]; ok {
+		status, err := parseGRPCCode(statusRaw)
+		if err != nil {
+			return nil, fmt.Errorf("'status' %w", err)
+		}
+		g.status = status
+	}
+
+	if messageRaw, ok := params["message"]; ok {
+		message, ok := messageRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'message' must be a string")
+		}
+		g.message = message
+	}
+
+	if mappingRaw, ok := params["statusCodeMapping"]; ok {
+		mappingList, ok := mappingRaw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'statusCodeMapping' must be an array")
+		}
+		for i, entryRaw := range mappingList {
+			entry, ok := entryRaw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'statusCodeMapping[%d]' must be an object", i)
+			}
+			httpStatus, ok := toInt(entry["httpStatus"])
+			if !ok || httpStatus < 100 || httpStatus > 599 {
+				return nil, fmt.Errorf("'statusCodeMapping[%d].httpStatus' must be an integer between 100 and 599", i)
+			}
+			grpcStatus, err := parseGRPCCode(entry["grpcStatus"])
+			if err != nil {
+				return nil, fmt.Errorf("'statusCodeMapping[%d].grpcStatus' %w", i, err)
+			}
+			g.statusMapping[httpStatus] = grpcStatus
+		}
+	}
+
+	descriptorSetFile, hasDescriptorSet := params["descriptorSetFile"].(string)
+	messageType, hasMessageType := params["messageType"].(string)
+	if hasDescriptorSet || hasMessageType {
+		if !hasDescriptorSet || !hasMessageType || descriptorSetFile == "" || messageType == "" {
+			return nil, fmt.Errorf("'descriptorSetFile' and 'messageType' must be provided together")
+		}
+		messageJSON, _ := params["messageJson"].(string)
+		payload, err := encodeProtoMessage(descriptorSetFile, messageType, messageJSON)
+		if err != nil {
+			return nil, err
+		}
+		g.frame = grpcFrame(payload)
+	}
+
+	return g, nil
+}
+
+// parseGRPCCode parses a gRPC status code given as a number or name (e.g. "UNAVAILABLE")
+func parseGRPCCode(value interface{}) (int, error) {
+	if code, ok := toInt(value); ok {
+		if code < 0 || code > 16 {
+			return 0, fmt.Errorf("must be between 0 and 16")
+		}
+		return code, nil
+	}
+	if name, ok := value.(string); ok {
+		if code, ok := grpcCodes[strings.ToUpper(name)]; ok {
+			return code, nil
+		}
+		return 0, fmt.Errorf("unknown gRPC status code %q", name)
+	}
+	return 0, fmt.Errorf("must be a gRPC status code number or name")
+}
+
+// encodeProtoMessage encodes the JSON message as the given type from a binary FileDescriptorSet
+func encodeProtoMessage(descriptorSetFile, messageType, messageJSON string) ([]byte, error) {
+	data, err := os.ReadFile(descriptorSetFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read 'descriptorSetFile': %w", err)
+	}
+
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, fmt.Errorf("'descriptorSetFile' is not a valid descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&fds)
+	if err != nil {
+		return nil, fmt.Errorf("'descriptorSetFile' is invalid (was it built with --include_imports?): %w", err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, fmt.Errorf("'messageType' %q not found in descriptor set: %w", messageType, err)
+	}
+	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'messageType' %q is not a message", messageType)
+	}
+
+	msg := dynamicpb.NewMessage(msgDesc)
+	if messageJSON != "" {
+		if err := protojson.Unmarshal([]byte(messageJSON), msg); err != nil {
+			return nil, fmt.Errorf("'messageJson' does not match %q: %w", messageType, err)
+		}
+	}
+	return proto.Marshal(msg)
+}
+
+// grpcFrame wraps a message in a gRPC length-prefixed, uncompressed frame
+func grpcFrame(payload []byte) []byte {
+	frame := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	return frame
+}
+
+// grpcStatusFor maps an HTTP status code to a gRPC status code
+func (g *grpcConfig) grpcStatusFor(httpStatus int) int {
+	if code, ok := g.statusMapping[httpStatus]; ok {
+		return code
+	}
+	if httpStatus >= 200 && httpStatus < 300 {
+		return grpcCodes["OK"]
+	}
+	return grpcCodes["UNKNOWN"]
+}
+
+// toResponse builds a gRPC response. Immediate responses cannot carry trailers, so the
+// status is sent in the headers; with a message frame the client reads it after the data.
+func (g *grpcConfig) toResponse(httpStatus int, headers map[string]string) policy.ImmediateResponse {
+	status := g.status
+	if status < 0 {
+		status = g.grpcStatusFor(httpStatus)
+	}
+
+	setHeader(headers, "content-type", GRPCContentType)
+	setHeader(headers, "grpc-status", strconv.Itoa(status))
+	if g.message != "" {
+		setHeader(headers, "grpc-message", encodeGRPCMessage(g.message))
+	}
+
+	var body []byte
+	if g.frame != nil {
+		body = append([]byte(nil), g.frame...)
+	}
+
+	// gRPC always uses HTTP 200; the outcome is carried by grpc-status
+	return policy.ImmediateResponse{
+		StatusCode: 200,
+		Headers:    headers,
+		Body:       body,
+	}
+}
+
+// encodeGRPCMessage percent-encodes grpc-message as required by the gRPC HTTP/2 protocol
+func encodeGRPCMessage(message string) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,300 @@
+package respond
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	// What requests matching no HAR entry receive
+	HAROnMissNotFound    = "notFound"
+	HAROnMissPassthrough = "passthrough"
+)
+
+// harNotFoundResponse is served to requests matching no HAR entry
+var harNotFoundResponse = immediateResponse{
+	statusCode: 404,
+	headers:    map[string]string{"Content-Type": "application/json"},
+	body:       []byte(`{"error": "Not Found", "message": "No HAR entry matches the request"}`),
+}
+
+// skippedHARHeaders are response headers not replayed from HAR entries. HAR content is
+// stored decoded, so the original encoding and length no longer apply.
+var skippedHARHeaders = map[string]bool{
+	"content-length":    true,
+	"content-encoding":  true,
+	"transfer-encoding": true,
+	"connection":        true,
+	"keep-alive":        true,
+}
+
+// harArchive is the subset of the HAR 1.2 format used for mocking
+type harArchive struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method   string `json:"method"`
+		URL      string `json:"url"`
+		PostData *struct {
+			Text string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int            `json:"status"`
+		Headers []harNameValue `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harMock serves HAR entries matching the request
+type harMock struct {
+	matchQuery bool
+	matchBody  bool
+	onMiss     string
+	// responses holds the first entry's response per match key
+	responses map[string]immediateResponse
+}
+
+// HARAmbiguity describes HAR entries sharing a match key, of which only the first is served
+type HARAmbiguity struct {
+	Method string
+	Path   string
+	Query  string
+	// Entries are the zero-based indexes of the entries in the archive
+	Entries []int
+	// ConflictingResponses is true if the entries do not all have the same status and body
+	ConflictingResponses bool
+}
+
+// parseHARMock parses the HAR configuration and loads the archive
+func parseHARMock(params map[string]interface{}) (*harMock, error) {
+	h := &harMock{matchQuery: true, onMiss: HAROnMissNotFound}
+
+	file, ok := params["file"].(string)
+	if !ok || file == "" {
+		return nil, fmt.Errorf("'file' is required and must be a non-empty string")
+	}
+
+	if matchQueryRaw, ok := params["matchQuery"]; ok {
+		matchQuery, ok := matchQueryRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'matchQuery' must be a boolean")
+		}
+		h.matchQuery = matchQuery
+	}
+
+	if matchBodyRaw, ok := params["matchBody"]; ok {
+		matchBody, ok := matchBodyRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'matchBody' must be a boolean")
+		}
+		h.matchBody = matchBody
+	}
+
+	if onMissRaw, ok := params["onMiss"]; ok {
+		onMiss, ok := onMissRaw.(string)
+		if !ok || (onMiss != HAROnMissNotFound && onMiss != HAROnMissPassthrough) {
+			return nil, fmt.Errorf("'onMiss' must be either %q or %q", HAROnMissNotFound, HAROnMissPassthrough)
+		}
+		h.onMiss = onMiss
+	}
+
+	archive, err := loadHAR(file)
+	if err != nil {
+		return nil, err
+	}
+
+	h.responses = make(map[string]immediateResponse, len(archive.Log.Entries))
+	for i, entry := range archive.Log.Entries {
+		if entry.aborted() {
+			continue
+		}
+		key, err := h.entryKey(entry)
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: %w", i, err)
+		}
+		if _, exists := h.responses[key]; exists {
+			continue
+		}
+		resp, err := harResponse(entry)
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: %w", i, err)
+		}
+		h.responses[key] = resp
+	}
+
+	return h, nil
+}
+
+// loadHAR reads and decodes a HAR archive
+func loadHAR(file string) (*harArchive, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read HAR file: %w", err)
+	}
+	var archive harArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	return &archive, nil
+}
+
+// aborted reports whether the entry is a request that got no response, e.g. one blocked or
+// cancelled in the browser. Browsers record these with status 0 or -1.
+func (e harEntry) aborted() bool {
+	return e.Response.Status <= 0
+}
+
+// entryKey builds the match key of a HAR entry
+func (h *harMock) entryKey(entry harEntry) (string, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid request URL: %w", err)
+	}
+	var body string
+	if entry.Request.PostData != nil {
+		body = entry.Request.PostData.Text
+	}
+	return h.matchKey(entry.Request.Method, u.EscapedPath(), u.RawQuery, body), nil
+}
+
+// matchKey builds the key requests and entries are matched by
+func (h *harMock) matchKey(method, path, rawQuery, body string) string {
+	if path == "" {
+		path = "/"
+	}
+	key := strings.ToUpper(method) + " " + path
+	if h.matchQuery && rawQuery != "" {
+		// Normalize parameter order so equivalent queries match
+		if query, err := url.ParseQuery(rawQuery); err == nil {
+			rawQuery = query.Encode()
+		}
+		key += "?" + rawQuery
+	}
+	if h.matchBody {
+		key += "\n" + strings.TrimSpace(body)
+	}
+	return key
+}
+
+// harResponse converts a HAR entry response to an immediate response
+func harResponse(entry harEntry) (immediateResponse, error) {
+	if entry.Response.Status < 100 || entry.Response.Status > 599 {
+		return immediateResponse{}, fmt.Errorf("invalid response status %d", entry.Response.Status)
+	}
+	resp := immediateResponse{
+		statusCode: entry.Response.Status,
+		headers:    make(map[string]string),
+	}
+
+	for _, header := range entry.Response.Headers {
+		name := strings.ToLower(header.Name)
+		if strings.HasPrefix(name, ":") || skippedHARHeaders[name] {
+			continue
+		}
+		if existing, ok := resp.headers[name]; ok {
+			resp.headers[name] = existing + ", " + header.Value
+		} else {
+			resp.headers[name] = header.Value
+		}
+	}
+	if _, ok := resp.headers["content-type"]; !ok && entry.Response.Content.MimeType != "" {
+		resp.headers["content-type"] = entry.Response.Content.MimeType
+	}
+
+	resp.body = []byte(entry.Response.Content.Text)
+	if entry.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+		if err != nil {
+			return resp, fmt.Errorf("invalid base64 response content: %w", err)
+		}
+		resp.body = decoded
+	}
+
+	return resp, nil
+}
+
+// match returns the response of the HAR entry matching the request
+func (h *harMock) match(ctx *policy.RequestContext) (immediateResponse, bool) {
+	path, rawQuery, _ := strings.Cut(ctx.Path, "?")
+	var body string
+	if h.matchBody && ctx.Body != nil {
+		body = string(ctx.Body.Content)
+	}
+	resp, ok := h.responses[h.matchKey(ctx.Method, path, rawQuery, body)]
+	return resp, ok
+}
+
+// CheckHAR reports HAR entries that would be ambiguous under the given matching rules,
+// i.e. entries sharing a match key where only the first one would ever be served.
+// Aborted entries are not served, so are not reported.
+func CheckHAR(file string, matchQuery, matchBody bool) ([]HARAmbiguity, error) {
+	archive, err := loadHAR(file)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &harMock{matchQuery: matchQuery, matchBody: matchBody}
+	groups := make(map[string][]int)
+	var keys []string
+	for i, entry := range archive.Log.Entries {
+		if entry.aborted() {
+			continue
+		}
+		key, err := h.entryKey(entry)
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: %w", i, err)
+		}
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var ambiguities []HARAmbiguity
+	for _, key := range keys {
+		indexes := groups[key]
+		if len(indexes) < 2 {
+			continue
+		}
+		first := archive.Log.Entries[indexes[0]]
+		u, _ := url.Parse(first.Request.URL)
+		ambiguity := HARAmbiguity{
+			Method:  strings.ToUpper(first.Request.Method),
+			Path:    u.EscapedPath(),
+			Entries: indexes,
+		}
+		if matchQuery {
+			ambiguity.Query = u.RawQuery
+		}
+		for _, i := range indexes[1:] {
+			other := archive.Log.Entries[i]
+			if other.Response.Status != first.Response.Status || other.Response.Content.Text != first.Response.Content.Text {
+				ambiguity.ConflictingResponses = true
+				break
+			}
+		}
+		ambiguities = append(ambiguities, ambiguity)
+	}
+
+	return ambiguities, nil
+}
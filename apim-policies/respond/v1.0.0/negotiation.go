@@ -0,0 +1,192 @@
+package respond
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// bodyVariant is a response body for a specific media type
+type bodyVariant struct {
+	mediaType string
+	body      []byte
+}
+
+// mediaRange is a parsed entry of an Accept header
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseBodyVariants extracts the media type keyed body variants from params.
+// Object and array bodies are serialized to JSON, and string bodies of a JSON media
+// type must be valid JSON.
+func parseBodyVariants(params map[string]interface{}) ([]bodyVariant, error) {
+	bodiesRaw, ok := params["bodies"]
+	if !ok {
+		return nil, nil
+	}
+	bodiesList, ok := bodiesRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'bodies' must be an array")
+	}
+	variants := make([]bodyVariant, 0, len(bodiesList))
+	for i, bodyRaw := range bodiesList {
+		bodyMap, ok := bodyRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'bodies[%d]' must be an object", i)
+		}
+		mediaType, _ := bodyMap["mediaType"].(string)
+		if _, _, ok := splitMediaType(mediaType); !ok {
+			return nil, fmt.Errorf("'bodies[%d].mediaType' is required and must be a media type such as application/json", i)
+		}
+		var body []byte
+		switch v := bodyMap["body"].(type) {
+		case string:
+			body = []byte(v)
+			if isJSONMediaType(mediaType) && len(body) > 0 && !json.Valid(body) {
+				return nil, fmt.Errorf("'bodies[%d].body' is not valid JSON but mediaType is %q", i, mediaType)
+			}
+		case map[string]interface{}, []interface{}:
+			serialized, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("'bodies[%d].body' cannot be serialized to JSON: %w", i, err)
+			}
+			body = serialized
+		default:
+			return nil, fmt.Errorf("'bodies[%d].body' is required and must be a string, object or array", i)
+		}
+		variants = append(variants, bodyVariant{mediaType: mediaType, body: body})
+	}
+	return variants, nil
+}
+
+// parseAccept parses an Accept header value into media ranges
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		typ, subtype, ok := splitMediaType(fields[0])
+		if !ok {
+			continue
+		}
+		r := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range fields[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+				r.q = q
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// splitMediaType splits "type/subtype; params" into its lower-cased type and subtype
+func splitMediaType(mediaType string) (string, string, bool) {
+	if idx := strings.IndexByte(mediaType, ';'); idx >= 0 {
+		mediaType = mediaType[:idx]
+	}
+	typ, subtype, found := strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+	if !found || typ == "" || subtype == "" {
+		return "", "", false
+	}
+	return typ, subtype, true
+}
+
+// negotiate selects the variant preferred by the Accept header values.
+// The most specific matching media range determines a variant's quality; variants with
+// quality 0 are not acceptable and ties are broken by configuration order.
+// Without an Accept header the first variant is selected.
+func negotiate(acceptValues []string, variants []bodyVariant) (bodyVariant, bool) {
+	if len(variants) == 0 {
+		return bodyVariant{}, false
+	}
+	ranges := parseAccept(strings.Join(acceptValues, ","))
+	if len(ranges) == 0 {
+		return variants[0], true
+	}
+
+	best := -1
+	bestQ := 0.0
+	for i, v := range variants {
+		typ, subtype, ok := splitMediaType(v.mediaType)
+		if !ok {
+			continue
+		}
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			var s int
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*" && r.subtype == "*":
+				s = 0
+			default:
+				continue
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	if best < 0 {
+		return bodyVariant{}, false
+	}
+	return variants[best], true
+}
+
+// notAcceptableBody builds the 406 response body listing the available media types
+func notAcceptableBody(variants []bodyVariant) []byte {
+	available := make([]string, 0, len(variants))
+	for _, v := range variants {
+		available = append(available, v.mediaType)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"error":     "Not Acceptable",
+		"message":   "None of the available representations match the Accept header",
+		"available": available,
+	})
+	if err != nil {
+		return []byte(`{"error": "Not Acceptable"}`)
+	}
+	return body
+}
+
+// setHeader sets a header, replacing any existing value regardless of name casing
+func setHeader(headers map[string]string, name, value string) {
+	for existing := range headers {
+		if strings.EqualFold(existing, name) {
+			delete(headers, existing)
+		}
+	}
+	headers[name] = value
+}
+
+// addVary adds a field to the Vary header, keeping any configured value
+func addVary(headers map[string]string, field string) {
+	for existing, value := range headers {
+		if !strings.EqualFold(existing, "vary") {
+			continue
+		}
+		for _, f := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+		headers[existing] = value + ", " + field
+		return
+	}
+	headers["Vary"] = field
+}
@@ -0,0 +1,96 @@
+package respond
+
+import (
+	"testing"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+func TestParseBodyVariants(t *testing.T) {
+	tests := []struct {
+		name    string
+		bodies  interface{}
+		want    []bodyVariant
+		wantErr bool
+	}{
+		{
+			name: "string bodies",
+			bodies: []interface{}{
+				map[string]interface{}{"mediaType": "application/json", "body": `{"ok": true}`},
+				map[string]interface{}{"mediaType": "text/plain", "body": "ok"},
+			},
+			want: []bodyVariant{
+				{mediaType: "application/json", body: []byte(`{"ok": true}`)},
+				{mediaType: "text/plain", body: []byte("ok")},
+			},
+		},
+		{
+			name:   "object body serialized to JSON",
+			bodies: []interface{}{map[string]interface{}{"mediaType": "application/json", "body": map[string]interface{}{"ok": true}}},
+			want:   []bodyVariant{{mediaType: "application/json", body: []byte(`{"ok":true}`)}},
+		},
+		{
+			name:    "not an array",
+			bodies:  "text/plain",
+			wantErr: true,
+		},
+		{
+			name:    "entry not an object",
+			bodies:  []interface{}{"text/plain"},
+			wantErr: true,
+		},
+		{
+			name:    "missing mediaType",
+			bodies:  []interface{}{map[string]interface{}{"body": "ok"}},
+			wantErr: true,
+		},
+		{
+			name:    "mediaType without subtype",
+			bodies:  []interface{}{map[string]interface{}{"mediaType": "text", "body": "ok"}},
+			wantErr: true,
+		},
+		{
+			name:    "missing body",
+			bodies:  []interface{}{map[string]interface{}{"mediaType": "text/plain"}},
+			wantErr: true,
+		},
+		{
+			name:    "numeric body",
+			bodies:  []interface{}{map[string]interface{}{"mediaType": "text/plain", "body": 42}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON for a JSON media type",
+			bodies:  []interface{}{map[string]interface{}{"mediaType": "application/problem+json", "body": "{oops"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{"bodies": tt.bodies}
+			got, err := parseBodyVariants(params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				// Invalid variants must also fail policy loading
+				if _, err := GetPolicy(policy.PolicyMetadata{}, params); err == nil {
+					t.Error("expected GetPolicy to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBodyVariants: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("variants = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].mediaType != tt.want[i].mediaType || string(got[i].body) != string(tt.want[i].body) {
+					t.Errorf("variant %d = %s %q, want %s %q", i, got[i].mediaType, got[i].body, tt.want[i].mediaType, tt.want[i].body)
+				}
+			}
+		})
+	}
+}
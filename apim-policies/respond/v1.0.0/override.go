@@ -0,0 +1,143 @@
+package respond
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Placeholders available in response override body templates
+	PlaceholderUpstreamStatus     = "${upstreamStatus}"
+	PlaceholderUpstreamStatusText = "${upstreamStatusText}"
+)
+
+// responseOverride rewrites upstream responses whose status matches one of the ranges
+type responseOverride struct {
+	statusRanges []statusRange
+	statusCode   int // 0 = keep upstream status
+	headers      map[string]string
+	body         string
+	hasBody      bool
+}
+
+// statusRange is an inclusive range of HTTP status codes
+type statusRange struct {
+	min int
+	max int
+}
+
+// parseResponseOverride parses and validates a response override configuration
+func parseResponseOverride(params map[string]interface{}) (*responseOverride, error) {
+	o := &responseOverride{}
+
+	statusCodesList, ok := params["upstreamStatusCodes"].([]interface{})
+	if !ok || len(statusCodesList) == 0 {
+		return nil, fmt.Errorf("'upstreamStatusCodes' is required and must be a non-empty array")
+	}
+	for i, statusCodeRaw := range statusCodesList {
+		r, err := parseStatusRange(statusCodeRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'upstreamStatusCodes[%d]': %w", i, err)
+		}
+		o.statusRanges = append(o.statusRanges, r)
+	}
+
+	if statusCodeRaw, ok := params["statusCode"]; ok {
+		statusCode, ok := toInt(statusCodeRaw)
+		if !ok || statusCode < 100 || statusCode > 599 {
+			return nil, fmt.Errorf("'statusCode' must be an integer between 100 and 599")
+		}
+		o.statusCode = statusCode
+	}
+
+	if bodyRaw, ok := params["body"]; ok {
+		body, ok := bodyRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'body' must be a string")
+		}
+		o.body = body
+		o.hasBody = true
+	}
+
+	// The body is a template, so only the headers are taken from the response parser
+	response, err := parseResponse(map[string]interface{}{"headers": params["headers"]})
+	if err != nil {
+		return nil, err
+	}
+	o.headers = response.headers
+
+	return o, nil
+}
+
+// parseStatusRange parses a status code (404), class ("5xx") or range ("500-504").
+// Codes must be valid HTTP statuses between 100 and 599.
+func parseStatusRange(value interface{}) (statusRange, error) {
+	r, err := parseStatusRangeValue(value)
+	if err != nil {
+		return r, err
+	}
+	if r.min < 100 || r.max > 599 {
+		return statusRange{}, fmt.Errorf("status codes must be between 100 and 599")
+	}
+	return r, nil
+}
+
+// parseStatusRangeValue parses a status code, class or range without validating the codes
+func parseStatusRangeValue(value interface{}) (statusRange, error) {
+	if code, ok := toInt(value); ok {
+		return statusRange{min: code, max: code}, nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return statusRange{}, fmt.Errorf("must be an integer or string")
+	}
+	s = strings.TrimSpace(strings.ToLower(s))
+
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		class := int(s[0]-'0') * 100
+		return statusRange{min: class, max: class + 99}, nil
+	}
+
+	if from, to, found := strings.Cut(s, "-"); found {
+		min, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return statusRange{}, fmt.Errorf("invalid range start %q", from)
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return statusRange{}, fmt.Errorf("invalid range end %q", to)
+		}
+		if min > max {
+			return statusRange{}, fmt.Errorf("range start %d is greater than end %d", min, max)
+		}
+		return statusRange{min: min, max: max}, nil
+	}
+
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return statusRange{}, fmt.Errorf("expected a status code, class (e.g. 5xx) or range (e.g. 500-504) but got %q", s)
+	}
+	return statusRange{min: code, max: code}, nil
+}
+
+// matches reports whether the override applies to the upstream status
+func (o *responseOverride) matches(status int) bool {
+	for _, r := range o.statusRanges {
+		if status >= r.min && status <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// renderBody substitutes upstream status placeholders in the body template
+func (o *responseOverride) renderBody(upstreamStatus int) []byte {
+	replacer := strings.NewReplacer(
+		PlaceholderUpstreamStatus, strconv.Itoa(upstreamStatus),
+		PlaceholderUpstreamStatusText, http.StatusText(upstreamStatus),
+	)
+	return []byte(replacer.Replace(o.body))
+}
@@ -0,0 +1,184 @@
+package respond
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
+)
+
+const (
+	DefaultRedirectStatusCode = 302
+)
+
+var (
+	redirectStatusCodes = map[int]bool{301: true, 302: true, 303: true, 307: true, 308: true}
+
+	// Matches ${name} placeholders in Location templates
+	templateVariableRegex = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+)
+
+// redirectConfig configures the redirect responder
+type redirectConfig struct {
+	statusCode    int
+	location      string
+	pathRegex     *regexp.Regexp
+	scheme        string
+	preserveQuery bool
+	allowedHosts  []string
+}
+
+// parseRedirectConfig parses and validates the redirect configuration
+func parseRedirectConfig(params map[string]interface{}) (*redirectConfig, error) {
+	r := &redirectConfig{
+		statusCode:    DefaultRedirectStatusCode,
+		preserveQuery: true,
+	}
+
+	location, ok := params["location"].(string)
+	if !ok || location == "" {
+		return nil, fmt.Errorf("'location' is required and must be a non-empty string")
+	}
+	r.location = location
+
+	if statusCodeRaw, ok := params["statusCode"]; ok {
+		statusCode, ok := toInt(statusCodeRaw)
+		if !ok || !redirectStatusCodes[statusCode] {
+			return nil, fmt.Errorf("'statusCode' must be one of 301, 302, 303, 307 or 308")
+		}
+		r.statusCode = statusCode
+	}
+
+	if pathRegexRaw, ok := params["pathRegex"]; ok {
+		pathRegex, ok := pathRegexRaw.(string)
+		if !ok || pathRegex == "" {
+			return nil, fmt.Errorf("'pathRegex' must be a non-empty string")
+		}
+		compiled, err := regexp.Compile(pathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("'pathRegex' is invalid: %w", err)
+		}
+		r.pathRegex = compiled
+	}
+
+	if schemeRaw, ok := params["scheme"]; ok {
+		scheme, ok := schemeRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'scheme' must be a string")
+		}
+		r.scheme = strings.ToLower(scheme)
+	}
+
+	if preserveQueryRaw, ok := params["preserveQuery"]; ok {
+		preserveQuery, ok := preserveQueryRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'preserveQuery' must be a boolean")
+		}
+		r.preserveQuery = preserveQuery
+	}
+
+	if allowedHostsRaw, ok := params["allowedHosts"]; ok {
+		allowedHosts, err := toStringSlice(allowedHostsRaw)
+		if err != nil {
+			return nil, fmt.Errorf("'allowedHosts' %w", err)
+		}
+		for i, host := range allowedHosts {
+			allowedHosts[i] = strings.ToLower(host)
+		}
+		r.allowedHosts = allowedHosts
+	}
+
+	return r, nil
+}
+
+// buildRedirect resolves the Location for the request.
+// Returns false if the request does not match the configured scheme or path regex.
+func (r *redirectConfig) buildRedirect(ctx *policy.RequestContext) (policy.RequestAction, bool) {
+	if r.scheme != "" && !strings.EqualFold(ctx.Scheme, r.scheme) {
+		return nil, false
+	}
+
+	path, rawQuery, _ := strings.Cut(ctx.Path, "?")
+
+	vars := map[string]string{
+		"path":   path,
+		"query":  rawQuery,
+		"host":   ctx.Authority,
+		"scheme": ctx.Scheme,
+	}
+
+	if r.pathRegex != nil {
+		matches := r.pathRegex.FindStringSubmatch(path)
+		if matches == nil {
+			return nil, false
+		}
+		for i, name := range r.pathRegex.SubexpNames() {
+			vars[strconv.Itoa(i)] = matches[i]
+			if name != "" {
+				vars[name] = matches[i]
+			}
+		}
+	}
+
+	location := templateVariableRegex.ReplaceAllStringFunc(r.location, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+
+	if r.preserveQuery && rawQuery != "" {
+		if strings.Contains(location, "?") {
+			location += "&" + rawQuery
+		} else {
+			location += "?" + rawQuery
+		}
+	}
+
+	if !r.isAllowedLocation(location) {
+		return policy.ImmediateResponse{
+			StatusCode: 400,
+			Headers: map[string]string{
+				"content-type": "application/json",
+			},
+			Body: []byte(`{"error": "Bad Request", "message": "Redirect target host is not allowed"}`),
+		}, true
+	}
+
+	return policy.ImmediateResponse{
+		StatusCode: r.statusCode,
+		Headers: map[string]string{
+			"location": location,
+		},
+	}, true
+}
+
+// isAllowedLocation guards against open redirects. When allowedHosts is configured,
+// absolute and protocol-relative locations must target one of the allowed hosts.
+func (r *redirectConfig) isAllowedLocation(location string) bool {
+	if len(r.allowedHosts) == 0 {
+		return true
+	}
+	// Browsers treat backslashes as slashes, e.g. "/\evil.com"
+	u, err := url.Parse(strings.ReplaceAll(location, `\`, "/"))
+	if err != nil {
+		return false
+	}
+	if u.Host == "" && u.Scheme == "" {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range r.allowedHosts {
+		if host == allowed {
+			return true
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,53 +1,352 @@
 package respond
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	policy "github.com/wso2/api-platform/sdk/gateway/policy/v1alpha"
 )
 
+const (
+	// Metadata key set by authentication policies, used to key scenario state per client
+	MetadataKeyAuthUser = "auth.username"
+
+	DefaultScenarioResetHeader = "x-respond-scenario-reset"
+	ResetAllScenarios          = "*"
+
+	// Phases the policy responds in
+	PhaseRequest  = "request"
+	PhaseResponse = "response"
+)
+
 // RespondPolicy implements immediate response functionality
-// This policy terminates the request processing and returns an immediate response to the client
-type RespondPolicy struct{}
+// In the request phase it terminates the request processing and returns an immediate response to the client.
+// In the response phase it rewrites upstream responses with matching status codes.
+type RespondPolicy struct {
+	// response is the configured statusCode, body and headers, resolved at load time
+	response          immediateResponse
+	phase             string
+	responseOverrides []*responseOverride
+	echo              *echoConfig
+	redirect          *redirectConfig
+	grpc              *grpcConfig
+	har               *harMock
+
+	scenarios        []*scenario
+	clientIDHeader   string
+	resetHeader      string
+	anonymousClients string
+
+	// state holds the number of matches per scenario per client
+	state *scenarioState
+}
+
+// scenario is a state machine that serves its steps in order, advancing on each match
+type scenario struct {
+	name   string
+	method string
+	path   string
+	steps  []scenarioStep
+	loop   bool
+}
+
+// scenarioStep is a response served for a number of consecutive matches
+type scenarioStep struct {
+	response immediateResponse
+	times    int
+}
 
-var ins = &RespondPolicy{}
+// immediateResponse holds a configured response
+type immediateResponse struct {
+	statusCode int
+	headers    map[string]string
+	body       []byte
+	// variants are negotiated against the Accept header and take precedence over body
+	variants []bodyVariant
+}
 
 func GetPolicy(
 	metadata policy.PolicyMetadata,
 	params map[string]interface{},
 ) (policy.Policy, error) {
-	return ins, nil
+	p := &RespondPolicy{
+		phase:            PhaseRequest,
+		resetHeader:      DefaultScenarioResetHeader,
+		anonymousClients: AnonymousClientsStateless,
+	}
+
+	// Extract the default response
+	response, err := parseResponse(params)
+	if err != nil {
+		return nil, err
+	}
+	p.response = response
+
+	// Extract optional phase
+	if phaseRaw, ok := params["phase"]; ok {
+		phase, ok := phaseRaw.(string)
+		if !ok || (phase != PhaseRequest && phase != PhaseResponse) {
+			return nil, fmt.Errorf("'phase' must be either %q or %q", PhaseRequest, PhaseResponse)
+		}
+		p.phase = phase
+	}
+
+	// Extract response overrides (required in response phase)
+	if overridesRaw, ok := params["responseOverrides"]; ok {
+		overridesList, ok := overridesRaw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'responseOverrides' must be an array")
+		}
+		for i, overrideRaw := range overridesList {
+			overrideMap, ok := overrideRaw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'responseOverrides[%d]' must be an object", i)
+			}
+			o, err := parseResponseOverride(overrideMap)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'responseOverrides[%d]': %w", i, err)
+			}
+			p.responseOverrides = append(p.responseOverrides, o)
+		}
+	}
+	if p.phase == PhaseResponse && len(p.responseOverrides) == 0 {
+		return nil, fmt.Errorf("'responseOverrides' is required when 'phase' is %q", PhaseResponse)
+	}
+
+	// Extract optional echo configuration
+	if echoRaw, ok := params["echo"]; ok {
+		echoMap, ok := echoRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'echo' must be an object")
+		}
+		if p.phase == PhaseResponse {
+			return nil, fmt.Errorf("'echo' is only supported when 'phase' is %q", PhaseRequest)
+		}
+		echo, err := parseEchoConfig(echoMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'echo': %w", err)
+		}
+		p.echo = echo
+	}
+
+	// Extract optional redirect configuration
+	if redirectRaw, ok := params["redirect"]; ok {
+		redirectMap, ok := redirectRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'redirect' must be an object")
+		}
+		if p.phase == PhaseResponse {
+			return nil, fmt.Errorf("'redirect' is only supported when 'phase' is %q", PhaseRequest)
+		}
+		redirect, err := parseRedirectConfig(redirectMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'redirect': %w", err)
+		}
+		p.redirect = redirect
+	}
+
+	// Extract optional gRPC configuration
+	if grpcRaw, ok := params["grpc"]; ok {
+		grpcMap, ok := grpcRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'grpc' must be an object")
+		}
+		if p.phase == PhaseResponse {
+			return nil, fmt.Errorf("'grpc' is only supported when 'phase' is %q", PhaseRequest)
+		}
+		grpc, err := parseGRPCConfig(grpcMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'grpc': %w", err)
+		}
+		p.grpc = grpc
+	}
+
+	// Extract optional HAR configuration
+	if harRaw, ok := params["har"]; ok {
+		harMap, ok := harRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'har' must be an object")
+		}
+		if p.phase == PhaseResponse {
+			return nil, fmt.Errorf("'har' is only supported when 'phase' is %q", PhaseRequest)
+		}
+		har, err := parseHARMock(harMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'har': %w", err)
+		}
+		p.har = har
+	}
+
+	// Extract optional scenarios
+	if scenariosRaw, ok := params["scenarios"]; ok {
+		scenariosList, ok := scenariosRaw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'scenarios' must be an array")
+		}
+		names := make(map[string]bool, len(scenariosList))
+		for i, scenarioRaw := range scenariosList {
+			scenarioMap, ok := scenarioRaw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'scenarios[%d]' must be an object", i)
+			}
+			s, err := parseScenario(scenarioMap)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'scenarios[%d]': %w", i, err)
+			}
+			if names[s.name] {
+				return nil, fmt.Errorf("duplicate scenario name: %q", s.name)
+			}
+			names[s.name] = true
+			p.scenarios = append(p.scenarios, s)
+		}
+	}
+
+	// Extract optional clientIdHeader
+	if clientIDHeaderRaw, ok := params["clientIdHeader"]; ok {
+		clientIDHeader, ok := clientIDHeaderRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'clientIdHeader' must be a string")
+		}
+		p.clientIDHeader = clientIDHeader
+	}
+
+	// Extract optional scenarioResetHeader
+	if resetHeaderRaw, ok := params["scenarioResetHeader"]; ok {
+		resetHeader, ok := resetHeaderRaw.(string)
+		if !ok || resetHeader == "" {
+			return nil, fmt.Errorf("'scenarioResetHeader' must be a non-empty string")
+		}
+		p.resetHeader = resetHeader
+	}
+
+	// Extract optional anonymousClients
+	if anonymousClientsRaw, ok := params["anonymousClients"]; ok {
+		anonymousClients, ok := anonymousClientsRaw.(string)
+		if !ok || (anonymousClients != AnonymousClientsStateless && anonymousClients != AnonymousClientsShared) {
+			return nil, fmt.Errorf("'anonymousClients' must be either %q or %q", AnonymousClientsStateless, AnonymousClientsShared)
+		}
+		p.anonymousClients = anonymousClients
+	}
+
+	// Extract optional scenario state bounds
+	maxClients := DefaultScenarioMaxClients
+	if maxClientsRaw, ok := params["scenarioMaxClients"]; ok {
+		maxClients, ok = toInt(maxClientsRaw)
+		if !ok || maxClients < 1 {
+			return nil, fmt.Errorf("'scenarioMaxClients' must be a positive integer")
+		}
+	}
+	stateTTL := DefaultScenarioStateTTL
+	if stateTTLRaw, ok := params["scenarioStateTtl"]; ok {
+		stateTTLStr, ok := stateTTLRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'scenarioStateTtl' must be a duration string, e.g. \"1h\"")
+		}
+		ttl, err := time.ParseDuration(stateTTLStr)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("'scenarioStateTtl' must be a positive duration, e.g. \"1h\"")
+		}
+		stateTTL = ttl
+	}
+	p.state = newScenarioState(maxClients, stateTTL)
+
+	return p, nil
 }
 
-// Mode returns the processing mode for this policy
-func (p *RespondPolicy) Mode() policy.ProcessingMode {
-	return policy.ProcessingMode{
-		RequestHeaderMode:  policy.HeaderModeProcess, // Can use request headers for context
-		RequestBodyMode:    policy.BodyModeSkip,      // Don't need request body
-		ResponseHeaderMode: policy.HeaderModeSkip,    // Returns immediate response
-		ResponseBodyMode:   policy.BodyModeSkip,      // Returns immediate response
+// parseScenario parses and validates a scenario configuration
+func parseScenario(params map[string]interface{}) (*scenario, error) {
+	s := &scenario{}
+
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("'name' is required and must be a non-empty string")
+	}
+	s.name = name
+
+	if methodRaw, ok := params["method"]; ok {
+		method, ok := methodRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'method' must be a string")
+		}
+		s.method = strings.ToUpper(method)
+	}
+
+	if pathRaw, ok := params["path"]; ok {
+		path, ok := pathRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'path' must be a string")
+		}
+		s.path = path
 	}
+
+	if loopRaw, ok := params["loop"]; ok {
+		loop, ok := loopRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'loop' must be a boolean")
+		}
+		s.loop = loop
+	}
+
+	stepsList, ok := params["steps"].([]interface{})
+	if !ok || len(stepsList) == 0 {
+		return nil, fmt.Errorf("'steps' is required and must be a non-empty array")
+	}
+	for i, stepRaw := range stepsList {
+		stepMap, ok := stepRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'steps[%d]' must be an object", i)
+		}
+		response, err := parseResponse(stepMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'steps[%d]': %w", i, err)
+		}
+		step := scenarioStep{
+			response: response,
+			times:    1,
+		}
+		if timesRaw, ok := stepMap["times"]; ok {
+			times, ok := toInt(timesRaw)
+			if !ok || times < 1 {
+				return nil, fmt.Errorf("'steps[%d].times' must be a positive integer", i)
+			}
+			step.times = times
+		}
+		s.steps = append(s.steps, step)
+	}
+
+	return s, nil
 }
 
-// OnRequest returns an immediate response to the client
-func (p *RespondPolicy) OnRequest(ctx *policy.RequestContext, params map[string]interface{}) policy.RequestAction {
+// parseResponse extracts statusCode, body and headers from params.
+// Object and array bodies are serialized to JSON, defaulting Content-Type to application/json,
+// and string bodies declared with a JSON Content-Type must be valid JSON.
+func parseResponse(params map[string]interface{}) (immediateResponse, error) {
 	// Extract statusCode (default to 200 OK)
 	statusCode := 200
 	if statusCodeRaw, ok := params["statusCode"]; ok {
-		switch v := statusCodeRaw.(type) {
-		case float64:
-			statusCode = int(v)
-		case int:
+		if v, ok := toInt(statusCodeRaw); ok {
 			statusCode = v
 		}
 	}
 
 	// Extract body
 	var body []byte
+	structuredBody := false
 	if bodyRaw, ok := params["body"]; ok {
 		switch v := bodyRaw.(type) {
 		case string:
 			body = []byte(v)
 		case []byte:
 			body = v
+		case map[string]interface{}, []interface{}:
+			serialized, err := json.Marshal(v)
+			if err != nil {
+				return immediateResponse{}, fmt.Errorf("'body' cannot be serialized to JSON: %w", err)
+			}
+			body = serialized
+			structuredBody = true
 		}
 	}
 
@@ -57,14 +356,176 @@ func (p *RespondPolicy) OnRequest(ctx *policy.RequestContext, params map[string]
 		if headersList, ok := headersRaw.([]interface{}); ok {
 			for _, headerRaw := range headersList {
 				if headerMap, ok := headerRaw.(map[string]interface{}); ok {
-					name := headerMap["name"].(string)
-					value := headerMap["value"].(string)
+					name, _ := headerMap["name"].(string)
+					value, _ := headerMap["value"].(string)
 					headers[name] = value
 				}
 			}
 		}
 	}
 
+	contentType, hasContentType := headerValue(headers, "content-type")
+	if structuredBody && !hasContentType {
+		headers["Content-Type"] = "application/json"
+	}
+	if !structuredBody && hasContentType && isJSONMediaType(contentType) && len(body) > 0 && !json.Valid(body) {
+		return immediateResponse{}, fmt.Errorf("'body' is not valid JSON but Content-Type is %q", contentType)
+	}
+
+	variants, err := parseBodyVariants(params)
+	if err != nil {
+		return immediateResponse{}, err
+	}
+
+	return immediateResponse{
+		statusCode: statusCode,
+		headers:    headers,
+		body:       body,
+		variants:   variants,
+	}, nil
+}
+
+// headerValue returns the value of a configured header regardless of name casing
+func headerValue(headers map[string]string, name string) (string, bool) {
+	for existing, value := range headers {
+		if strings.EqualFold(existing, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// isJSONMediaType reports whether a Content-Type is application/json or a +json type
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// toInt converts numeric parameter values to int
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// Mode returns the processing mode for this policy
+func (p *RespondPolicy) Mode() policy.ProcessingMode {
+	if p.phase == PhaseResponse {
+		return policy.ProcessingMode{
+			RequestHeaderMode:  policy.HeaderModeSkip,    // Request is forwarded unchanged
+			RequestBodyMode:    policy.BodyModeSkip,      // Don't need request body
+			ResponseHeaderMode: policy.HeaderModeProcess, // Need upstream status
+			ResponseBodyMode:   policy.BodyModeBuffer,    // Replaces upstream body
+		}
+	}
+	if (p.echo != nil && p.echo.includeBody) || (p.har != nil && p.har.matchBody) {
+		return policy.ProcessingMode{
+			RequestHeaderMode:  policy.HeaderModeProcess, // Can use request headers for context
+			RequestBodyMode:    policy.BodyModeBuffer,    // Echoes or matches request body
+			ResponseHeaderMode: policy.HeaderModeSkip,    // Returns immediate response
+			ResponseBodyMode:   policy.BodyModeSkip,      // Returns immediate response
+		}
+	}
+	return policy.ProcessingMode{
+		RequestHeaderMode:  policy.HeaderModeProcess, // Can use request headers for context
+		RequestBodyMode:    policy.BodyModeSkip,      // Don't need request body
+		ResponseHeaderMode: policy.HeaderModeSkip,    // Returns immediate response
+		ResponseBodyMode:   policy.BodyModeSkip,      // Returns immediate response
+	}
+}
+
+// OnRequest returns an immediate response to the client
+func (p *RespondPolicy) OnRequest(ctx *policy.RequestContext, params map[string]interface{}) policy.RequestAction {
+	if p.phase == PhaseResponse {
+		return policy.UpstreamRequestModifications{}
+	}
+
+	resp := p.response
+
+	// Echo the received request back to the client
+	if p.echo != nil {
+		headers := make(map[string]string, len(resp.headers)+1)
+		for name, value := range resp.headers {
+			headers[name] = value
+		}
+		setHeader(headers, "Content-Type", "application/json")
+		return policy.ImmediateResponse{
+			StatusCode: resp.statusCode,
+			Headers:    headers,
+			Body:       p.echo.buildEchoBody(ctx),
+		}
+	}
+
+	// Redirect matching requests, letting the others continue to the upstream
+	if p.redirect != nil {
+		if action, ok := p.redirect.buildRedirect(ctx); ok {
+			return action
+		}
+		return policy.UpstreamRequestModifications{}
+	}
+
+	matched := false
+	if len(p.scenarios) > 0 {
+		clientKey := p.clientKey(ctx)
+		stateful := clientKey != "" || p.anonymousClients == AnonymousClientsShared
+		if resetValues := ctx.Headers.Get(p.resetHeader); len(resetValues) > 0 && stateful {
+			p.resetScenarios(clientKey, resetValues)
+		}
+		if s := p.matchScenario(ctx); s != nil {
+			if stateful {
+				resp = p.advanceScenario(s, clientKey)
+			} else {
+				// Anonymous clients cannot be told apart, so none of them advances the scenario
+				resp = s.stepFor(0).response
+			}
+			matched = true
+		}
+	}
+
+	// Serve the HAR entry matching the request
+	if !matched && p.har != nil {
+		harResp, ok := p.har.match(ctx)
+		if !ok {
+			if p.har.onMiss == HAROnMissPassthrough {
+				return policy.UpstreamRequestModifications{}
+			}
+			harResp = harNotFoundResponse
+		}
+		resp = harResp
+	}
+
+	headers := make(map[string]string, len(resp.headers))
+	for name, value := range resp.headers {
+		headers[name] = value
+	}
+	statusCode := resp.statusCode
+	body := resp.body
+
+	// Translate the response for gRPC clients
+	if p.grpc != nil {
+		return p.grpc.toResponse(statusCode, headers)
+	}
+
+	// Select the body variant from the Accept header
+	if len(resp.variants) > 0 {
+		addVary(headers, "Accept")
+		if variant, ok := negotiate(ctx.Headers.Get("accept"), resp.variants); ok {
+			setHeader(headers, "Content-Type", variant.mediaType)
+			body = variant.body
+		} else {
+			setHeader(headers, "Content-Type", "application/json")
+			statusCode = 406
+			body = notAcceptableBody(resp.variants)
+		}
+	}
+
 	// Return immediate response action
 	return policy.ImmediateResponse{
 		StatusCode: statusCode,
@@ -73,7 +534,106 @@ func (p *RespondPolicy) OnRequest(ctx *policy.RequestContext, params map[string]
 	}
 }
 
-// OnResponse is not used by this policy (returns immediate response in request phase)
+// OnResponse rewrites the upstream response using the first override matching its status
 func (p *RespondPolicy) OnResponse(ctx *policy.ResponseContext, params map[string]interface{}) policy.ResponseAction {
-	return nil // No response processing needed
+	if p.phase != PhaseResponse {
+		return nil // No response processing needed
+	}
+
+	upstreamStatus := ctx.ResponseStatus
+	for _, o := range p.responseOverrides {
+		if !o.matches(upstreamStatus) {
+			continue
+		}
+
+		mods := policy.UpstreamResponseModifications{
+			SetHeaders: make(map[string]string, len(o.headers)),
+		}
+		for name, value := range o.headers {
+			mods.SetHeaders[name] = value
+		}
+		if o.statusCode != 0 {
+			statusCode := o.statusCode
+			mods.StatusCode = &statusCode
+		}
+		if o.hasBody {
+			mods.Body = o.renderBody(upstreamStatus)
+		}
+		return mods
+	}
+
+	return policy.UpstreamResponseModifications{}
+}
+
+// clientKey identifies the client whose scenario state is used, empty for anonymous clients.
+// The configured client ID header takes precedence over the authenticated username.
+func (p *RespondPolicy) clientKey(ctx *policy.RequestContext) string {
+	if p.clientIDHeader != "" {
+		if values := ctx.Headers.Get(p.clientIDHeader); len(values) > 0 && values[0] != "" {
+			return "header:" + values[0]
+		}
+	}
+	if username, ok := ctx.Metadata[MetadataKeyAuthUser].(string); ok && username != "" {
+		return "user:" + username
+	}
+	return ""
+}
+
+// matchScenario returns the first scenario matching the request method and path
+func (p *RespondPolicy) matchScenario(ctx *policy.RequestContext) *scenario {
+	path := ctx.Path
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		path = path[:idx]
+	}
+	for _, s := range p.scenarios {
+		if s.method != "" && !strings.EqualFold(s.method, ctx.Method) {
+			continue
+		}
+		if s.path != "" && s.path != path {
+			continue
+		}
+		return s
+	}
+	return nil
+}
+
+// advanceScenario records a match for the client and returns the step response for it
+func (p *RespondPolicy) advanceScenario(s *scenario, clientKey string) immediateResponse {
+	count := p.state.advance(s.name, clientKey, time.Now())
+	return s.stepFor(count).response
+}
+
+// resetScenarios clears the client's state for the scenarios named in the reset header
+func (p *RespondPolicy) resetScenarios(clientKey string, values []string) {
+	var names []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == ResetAllScenarios {
+				p.state.reset(clientKey, nil, true)
+				return
+			}
+			names = append(names, name)
+		}
+	}
+	p.state.reset(clientKey, names, false)
+}
+
+// stepFor returns the step serving the given zero-based match count.
+// Once all steps are served the last step repeats, unless the scenario loops.
+func (s *scenario) stepFor(count int) scenarioStep {
+	total := 0
+	for _, step := range s.steps {
+		total += step.times
+	}
+	if s.loop {
+		count %= total
+	}
+	for _, step := range s.steps {
+		if count < step.times {
+			return step
+		}
+		count -= step.times
+	}
+	return s.steps[len(s.steps)-1]
 }
@@ -0,0 +1,104 @@
+package respond
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultScenarioMaxClients = 10000
+	DefaultScenarioStateTTL   = time.Hour
+
+	// How requests without a client identity are handled by scenarios
+	AnonymousClientsStateless = "stateless"
+	AnonymousClientsShared    = "shared"
+)
+
+// scenarioState tracks the scenario matches of clients in memory. Clients idle for the TTL are
+// evicted, and beyond maxClients the least recently used client is dropped, so client IDs taken
+// from request headers cannot grow the state without bound.
+type scenarioState struct {
+	mu         sync.Mutex
+	maxClients int
+	ttl        time.Duration
+	clients    map[string]*list.Element
+	// lru orders clients from most to least recently used
+	lru *list.List
+}
+
+// clientState is the number of matches per scenario of a client
+type clientState struct {
+	key      string
+	counts   map[string]int
+	lastSeen time.Time
+}
+
+func newScenarioState(maxClients int, ttl time.Duration) *scenarioState {
+	return &scenarioState{
+		maxClients: maxClients,
+		ttl:        ttl,
+		clients:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// advance records a match of the scenario for the client and returns the zero-based number of
+// matches before it
+func (s *scenarioState) advance(scenarioName, clientKey string, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired(now.Add(-s.ttl))
+
+	var client *clientState
+	if elem, ok := s.clients[clientKey]; ok {
+		client = elem.Value.(*clientState)
+		s.lru.MoveToFront(elem)
+	} else {
+		if s.lru.Len() >= s.maxClients {
+			s.evict(s.lru.Back())
+		}
+		client = &clientState{key: clientKey, counts: make(map[string]int)}
+		s.clients[clientKey] = s.lru.PushFront(client)
+	}
+	client.lastSeen = now
+
+	count := client.counts[scenarioName]
+	client.counts[scenarioName] = count + 1
+	return count
+}
+
+// reset clears the client's state for the named scenarios, or all scenarios if all is set
+func (s *scenarioState) reset(clientKey string, scenarioNames []string, all bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.clients[clientKey]
+	if !ok {
+		return
+	}
+	if all {
+		s.evict(elem)
+		return
+	}
+	client := elem.Value.(*clientState)
+	for _, name := range scenarioNames {
+		delete(client.counts, name)
+	}
+}
+
+// evictExpired evicts the clients not seen since the cutoff
+func (s *scenarioState) evictExpired(cutoff time.Time) {
+	for elem := s.lru.Back(); elem != nil; elem = s.lru.Back() {
+		if elem.Value.(*clientState).lastSeen.After(cutoff) {
+			return
+		}
+		s.evict(elem)
+	}
+}
+
+func (s *scenarioState) evict(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.clients, elem.Value.(*clientState).key)
+}